@@ -0,0 +1,265 @@
+package database
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+)
+
+// A Cache is an in-memory cache of decoded documents, indexed by key.
+// It holds at most a fixed number of documents and evicts the least recently used ones first.
+// Once associated with a table using Database.SetCache, documents returned by Table.GetDocument
+// are cached and subsequent lookups of the same key are served from memory.
+// Documents are removed from the cache when a transaction that modified them is committed.
+// A transaction never reads uncommitted changes of another transaction from the cache,
+// and bypasses it for the tables it modified.
+// Documents returned from the cache are shared and must not be modified.
+// It is safe for concurrent use.
+type Cache struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	key string
+	d   *cachedDocument
+}
+
+// cachedDocument is a fully decoded document that remembers its key.
+type cachedDocument struct {
+	*document.FieldBuffer
+
+	key []byte
+}
+
+func (c *cachedDocument) Key() []byte {
+	return c.key
+}
+
+// NewCache creates a cache that holds at most size documents.
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &Cache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// CacheStats holds the statistics of a cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// HitRatio returns the ratio of lookups served by the cache.
+// It returns 0 if the cache was never used.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Len:    c.ll.Len(),
+	}
+}
+
+func (c *Cache) get(key []byte) (document.Document, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[string(key)]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.ll.MoveToFront(e)
+	return e.Value.(*cacheEntry).d, true
+}
+
+// add decodes the encoded document and stores it in the cache.
+// The data is copied, it can be safely reused by the caller.
+func (c *Cache) add(key []byte, data []byte) error {
+	var fb document.FieldBuffer
+	err := fb.Copy(encoding.EncodedDocument(append([]byte(nil), data...)))
+	if err != nil {
+		return err
+	}
+
+	d := cachedDocument{
+		FieldBuffer: &fb,
+		key:         append([]byte(nil), key...),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[string(key)]; ok {
+		e.Value.(*cacheEntry).d = &d
+		c.ll.MoveToFront(e)
+		return nil
+	}
+
+	c.items[string(key)] = c.ll.PushFront(&cacheEntry{key: string(key), d: &d})
+	if c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).key)
+	}
+
+	return nil
+}
+
+func (c *Cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+func (c *Cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// txCache tracks the documents of cached tables modified by a transaction.
+type txCache struct {
+	// version of the caches when the transaction started.
+	// The caches can only be used by the transaction as long as this version
+	// is the current one.
+	version uint64
+	// keys modified by the transaction, by table
+	keys map[string][]string
+	// tables truncated or dropped by the transaction
+	purged map[string]bool
+}
+
+func (c *txCache) modified(tableName string) bool {
+	_, ok := c.keys[tableName]
+	return ok
+}
+
+// SetCache associates a document cache with the given table.
+// If c is nil, the table cache is removed.
+// It must be called before the table is used by any transaction,
+// typically right after opening the database.
+// A cache must not be shared by multiple tables.
+func (db *Database) SetCache(tableName string, c *Cache) {
+	db.cacheMu.Lock()
+	defer db.cacheMu.Unlock()
+
+	if c == nil {
+		delete(db.caches, tableName)
+	} else {
+		if db.caches == nil {
+			db.caches = make(map[string]*Cache)
+		}
+		db.caches[tableName] = c
+	}
+
+	db.cacheVersion++
+}
+
+// newTxCache returns the cache state of a new transaction,
+// or nil if no cache is used by the database.
+func (db *Database) newTxCache() *txCache {
+	db.cacheMu.RLock()
+	defer db.cacheMu.RUnlock()
+
+	if len(db.caches) == 0 {
+		return nil
+	}
+
+	return &txCache{
+		version: db.cacheVersion,
+		keys:    make(map[string][]string),
+		purged:  make(map[string]bool),
+	}
+}
+
+func (db *Database) getCache(tableName string) *Cache {
+	db.cacheMu.RLock()
+	defer db.cacheMu.RUnlock()
+
+	return db.caches[tableName]
+}
+
+// cacheGet returns the document associated with the key if the cache
+// is still valid for the given version.
+func (db *Database) cacheGet(c *Cache, version uint64, key []byte) (document.Document, bool) {
+	db.cacheMu.RLock()
+	defer db.cacheMu.RUnlock()
+
+	if db.cacheVersion != version {
+		return nil, false
+	}
+
+	return c.get(key)
+}
+
+// cacheAdd stores the document in the cache if the cache
+// is still valid for the given version.
+func (db *Database) cacheAdd(c *Cache, version uint64, key, data []byte) error {
+	db.cacheMu.RLock()
+	defer db.cacheMu.RUnlock()
+
+	if db.cacheVersion != version {
+		return nil
+	}
+
+	return c.add(key, data)
+}
+
+// invalidateCaches removes the documents modified by a transaction from the caches.
+// It is called twice, before and after the transaction is committed, so that no transaction
+// started while the commit is in progress can use the caches.
+func (db *Database) invalidateCaches(tc *txCache) {
+	db.cacheMu.Lock()
+	defer db.cacheMu.Unlock()
+
+	for tableName, keys := range tc.keys {
+		c, ok := db.caches[tableName]
+		if !ok {
+			continue
+		}
+
+		if tc.purged[tableName] {
+			c.purge()
+			continue
+		}
+
+		for _, k := range keys {
+			c.remove(k)
+		}
+	}
+
+	db.cacheVersion++
+}
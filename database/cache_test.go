@@ -0,0 +1,191 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	setup := func(t *testing.T, size int) (*database.Database, *database.Cache) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+
+		c := database.NewCache(size)
+		db.SetCache("test", c)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			_, err = tb.Insert(document.NewFieldBuffer().
+				Add("id", document.NewInt64Value(int64(i))).
+				Add("a", document.NewTextValue("foo")))
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, tx.Commit())
+		return db, c
+	}
+
+	key := func(t *testing.T, tb *database.Table, id int64) []byte {
+		var k []byte
+		err := tb.Iterate(func(d document.Document) error {
+			v, err := d.GetByField("id")
+			if err != nil {
+				return err
+			}
+			if v.V.(int64) == id {
+				k = append([]byte(nil), d.(document.Keyer).Key()...)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.NotNil(t, k)
+		return k
+	}
+
+	getA := func(t *testing.T, tb *database.Table, k []byte) string {
+		d, err := tb.GetDocument(k)
+		require.NoError(t, err)
+		require.Equal(t, k, d.(document.Keyer).Key())
+		v, err := d.GetByField("a")
+		require.NoError(t, err)
+		s, err := v.ConvertToText()
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("Should cache documents", func(t *testing.T) {
+		db, c := setup(t, 10)
+		defer db.Close()
+
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		k := key(t, tb, 1)
+
+		require.Equal(t, "foo", getA(t, tb, k))
+		require.Equal(t, "foo", getA(t, tb, k))
+		require.Equal(t, "foo", getA(t, tb, k))
+
+		stats := c.Stats()
+		require.Equal(t, database.CacheStats{Hits: 2, Misses: 1, Len: 1}, stats)
+		require.InDelta(t, 2.0/3, stats.HitRatio(), 0.001)
+
+		_, err = tb.GetDocument([]byte("unknown"))
+		require.Equal(t, database.ErrDocumentNotFound, err)
+	})
+
+	t.Run("Should evict the least recently used documents", func(t *testing.T) {
+		db, c := setup(t, 2)
+		defer db.Close()
+
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		k0, k1, k2 := key(t, tb, 0), key(t, tb, 1), key(t, tb, 2)
+
+		getA(t, tb, k0)
+		getA(t, tb, k1)
+		getA(t, tb, k0)
+		getA(t, tb, k2)
+		require.Equal(t, database.CacheStats{Hits: 1, Misses: 3, Len: 2}, c.Stats())
+
+		// k1 was evicted
+		getA(t, tb, k1)
+		require.Equal(t, database.CacheStats{Hits: 1, Misses: 4, Len: 2}, c.Stats())
+	})
+
+	t.Run("Should only reflect committed changes", func(t *testing.T) {
+		db, c := setup(t, 10)
+		defer db.Close()
+
+		wtx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer wtx.Rollback()
+
+		wtb, err := wtx.GetTable("test")
+		require.NoError(t, err)
+		k := key(t, wtb, 1)
+
+		// populate the cache
+		require.Equal(t, "foo", getA(t, wtb, k))
+
+		err = wtb.Replace(k, document.NewFieldBuffer().
+			Add("id", document.NewInt64Value(1)).
+			Add("a", document.NewTextValue("bar")))
+		require.NoError(t, err)
+
+		// the writer must see its own changes
+		require.Equal(t, "bar", getA(t, wtb, k))
+
+		// other transactions must not see uncommitted changes
+		rtx, err := db.Begin(false)
+		require.NoError(t, err)
+		rtb, err := rtx.GetTable("test")
+		require.NoError(t, err)
+		require.Equal(t, "foo", getA(t, rtb, k))
+
+		require.NoError(t, wtx.Commit())
+
+		// transactions started before the commit don't use the cache anymore
+		require.Equal(t, "foo", getA(t, rtb, k))
+		require.NoError(t, rtx.Rollback())
+
+		require.Zero(t, c.Stats().Len)
+
+		rtx, err = db.Begin(false)
+		require.NoError(t, err)
+		defer rtx.Rollback()
+		rtb, err = rtx.GetTable("test")
+		require.NoError(t, err)
+		require.Equal(t, "bar", getA(t, rtb, k))
+		require.Equal(t, "bar", getA(t, rtb, k))
+	})
+
+	t.Run("Should not cache deleted documents", func(t *testing.T) {
+		db, c := setup(t, 10)
+		defer db.Close()
+
+		err := func() error {
+			tx, err := db.Begin(true)
+			require.NoError(t, err)
+			defer tx.Rollback()
+
+			tb, err := tx.GetTable("test")
+			require.NoError(t, err)
+			k := key(t, tb, 1)
+			getA(t, tb, k)
+			require.Equal(t, 1, c.Stats().Len)
+
+			require.NoError(t, tb.Delete(k))
+			_, err = tb.GetDocument(k)
+			require.Equal(t, database.ErrDocumentNotFound, err)
+
+			return tx.Commit()
+		}()
+		require.NoError(t, err)
+		require.Zero(t, c.Stats().Len)
+	})
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/index"
+)
+
+// A Discrepancy describes an inconsistency between a document and an index.
+type Discrepancy struct {
+	TableName string
+	IndexName string
+	// Key of the document.
+	Key []byte
+	// Value of the indexed field, or of the index entry if the document doesn't exist.
+	Value  document.Value
+	Reason string
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("index %q of table %q, key %q, value %s: %s", d.IndexName, d.TableName, d.Key, d.Value, d.Reason)
+}
+
+// List of reasons returned by Check.
+const (
+	ReasonMissingEntry     = "missing index entry"
+	ReasonDanglingEntry    = "index entry references a missing document"
+	ReasonMismatchingEntry = "index entry value doesn't match the document"
+	ReasonDuplicateValue   = "duplicate value in unique index"
+)
+
+// Check verifies the consistency of every index of the database.
+// It ensures that every document has an index entry matching the value of the indexed field,
+// that every index entry references an existing document with the same value and that
+// no two documents share the same value in a unique index.
+// It returns all the discrepancies found. If repair is true, indexes with discrepancies are
+// rebuilt from the documents of their table, which requires a read-write transaction.
+func (tx Transaction) Check(repair bool) ([]Discrepancy, error) {
+	var indexes []string
+
+	err := tx.indexStore.st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		indexes = append(indexes, string(k))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Discrepancy
+	for _, indexName := range indexes {
+		idx, err := tx.GetIndex(indexName)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := tx.checkIndex(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) > 0 && repair {
+			err = tx.ReIndex(indexName)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		all = append(all, list...)
+	}
+
+	return all, nil
+}
+
+func (tx Transaction) checkIndex(idx *Index) ([]Discrepancy, error) {
+	tb, err := tx.GetTable(idx.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Discrepancy
+	report := func(key []byte, v document.Value, reason string) {
+		list = append(list, Discrepancy{
+			TableName: idx.TableName,
+			IndexName: idx.IndexName,
+			Key:       append([]byte(nil), key...),
+			Value:     v,
+			Reason:    reason,
+		})
+	}
+
+	// load all the index entries, by key
+	entries := make(map[string][]document.Value)
+	err = idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+		entries[string(key)] = append(entries[string(key)], val)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// values already seen in a unique index, by encoded entry
+	seen := make(map[string]bool)
+
+	err = tb.Iterate(func(d document.Document) error {
+		key := d.(document.Keyer).Key()
+
+		v, err := idx.Path.GetValue(d)
+		if err != nil && err != document.ErrFieldNotFound {
+			return err
+		}
+		// documents without the indexed field may or may not be indexed as null
+		missing := err == document.ErrFieldNotFound
+		if missing {
+			v = document.NewNullValue()
+		}
+
+		values := entries[string(key)]
+		delete(entries, string(key))
+
+		enc, err := encodeIndexEntry(v)
+		if err != nil {
+			return err
+		}
+
+		var found bool
+		for _, ev := range values {
+			eenc, err := encodeIndexEntry(ev)
+			if err != nil {
+				return err
+			}
+
+			ok := bytes.Equal(enc, eenc)
+			if ok && !found {
+				found = true
+				continue
+			}
+
+			report(key, ev, ReasonMismatchingEntry)
+		}
+
+		if !found && !missing {
+			report(key, v, ReasonMissingEntry)
+		}
+
+		if idx.Unique && !missing {
+			if seen[string(enc)] {
+				report(key, v, ReasonDuplicateValue)
+			}
+			seen[string(enc)] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// remaining entries reference documents that don't exist
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, v := range entries[key] {
+			report([]byte(key), v, ReasonDanglingEntry)
+		}
+	}
+
+	return list, nil
+}
+
+// encodeIndexEntry returns the index type of v followed by its encoded value, as written by the index.
+// Values are compared using their encoded entries, because values that are considered equal
+// by the index, like an integer and a float, or a text and a blob, may not be decoded with
+// the same type.
+func encodeIndexEntry(v document.Value) ([]byte, error) {
+	enc, err := index.EncodeFieldToIndexValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{byte(index.NewTypeFromValueType(v.Type))}, enc...), nil
+}
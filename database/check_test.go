@@ -0,0 +1,146 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxCheck(t *testing.T) {
+	setup := func(t *testing.T, unique bool) (*database.Transaction, *database.Index, [][]byte, func()) {
+		tx, cleanup := newTestDB(t)
+
+		err := tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idx_a", TableName: "test", Path: document.NewValuePath("a"), Unique: unique,
+		})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		var keys [][]byte
+		for i := 0; i < 5; i++ {
+			key, err := tb.Insert(document.NewFieldBuffer().Add("a", document.NewIntValue(i)))
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		// documents without the indexed field are valid
+		_, err = tb.Insert(document.NewFieldBuffer().Add("b", document.NewIntValue(1)))
+		require.NoError(t, err)
+
+		idx, err := tx.GetIndex("idx_a")
+		require.NoError(t, err)
+
+		return tx, idx, keys, cleanup
+	}
+
+	t.Run("Should not report anything if the database is consistent", func(t *testing.T) {
+		tx, _, _, cleanup := setup(t, false)
+		defer cleanup()
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		require.Empty(t, list)
+	})
+
+	t.Run("Should report every discrepancy", func(t *testing.T) {
+		tx, idx, keys, cleanup := setup(t, false)
+		defer cleanup()
+
+		require.NoError(t, idx.Delete(document.NewIntValue(1), keys[1]))
+		require.NoError(t, idx.Delete(document.NewIntValue(2), keys[2]))
+		require.NoError(t, idx.Set(document.NewIntValue(20), keys[2]))
+		require.NoError(t, idx.Set(document.NewIntValue(30), []byte("unknown")))
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		require.Equal(t, []database.Discrepancy{
+			{TableName: "test", IndexName: "idx_a", Key: keys[1], Value: document.NewIntValue(1), Reason: database.ReasonMissingEntry},
+			{TableName: "test", IndexName: "idx_a", Key: keys[2], Value: document.NewFloat64Value(20), Reason: database.ReasonMismatchingEntry},
+			{TableName: "test", IndexName: "idx_a", Key: keys[2], Value: document.NewIntValue(2), Reason: database.ReasonMissingEntry},
+			{TableName: "test", IndexName: "idx_a", Key: []byte("unknown"), Value: document.NewFloat64Value(30), Reason: database.ReasonDanglingEntry},
+		}, list)
+	})
+
+	t.Run("Should repair the indexes", func(t *testing.T) {
+		tx, idx, keys, cleanup := setup(t, false)
+		defer cleanup()
+
+		require.NoError(t, idx.Delete(document.NewIntValue(1), keys[1]))
+		require.NoError(t, idx.Set(document.NewIntValue(30), []byte("unknown")))
+
+		list, err := tx.Check(true)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+
+		list, err = tx.Check(false)
+		require.NoError(t, err)
+		require.Empty(t, list)
+	})
+
+	t.Run("Should report duplicates in unique indexes", func(t *testing.T) {
+		tx, _, keys, cleanup := setup(t, true)
+		defer cleanup()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		// bypass the index to insert a duplicate
+		data, err := encoding.EncodeDocument(document.NewFieldBuffer().Add("a", document.NewIntValue(3)))
+		require.NoError(t, err)
+		err = tb.Store.Put([]byte("dup"), data)
+		require.NoError(t, err)
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		require.Equal(t, []database.Discrepancy{
+			{TableName: "test", IndexName: "idx_a", Key: []byte("dup"), Value: document.NewIntValue(3), Reason: database.ReasonMissingEntry},
+			{TableName: "test", IndexName: "idx_a", Key: keys[3], Value: document.NewIntValue(3), Reason: database.ReasonDuplicateValue},
+		}, list)
+	})
+	t.Run("Should check documents and arrays", func(t *testing.T) {
+		for _, unique := range []bool{false, true} {
+			tx, idx, _, cleanup := setup(t, unique)
+			defer cleanup()
+
+			tb, err := tx.GetTable("test")
+			require.NoError(t, err)
+
+			doc := document.NewDocumentValue(document.NewFieldBuffer().
+				Add("c", document.NewArrayValue(document.NewValueBuffer(document.NewBoolValue(true)))).
+				Add("b", document.NewIntValue(1)))
+			arr := document.NewArrayValue(document.NewValueBuffer(document.NewIntValue(1), document.NewTextValue("x")))
+
+			var keys [][]byte
+			for _, v := range []document.Value{doc, arr} {
+				key, err := tb.Insert(document.NewFieldBuffer().Add("a", v))
+				require.NoError(t, err)
+				keys = append(keys, key)
+			}
+
+			list, err := tx.Check(true)
+			require.NoError(t, err)
+			require.Empty(t, list)
+
+			// an entry with a different document is reported
+			other := document.NewDocumentValue(document.NewFieldBuffer().Add("b", document.NewIntValue(2)))
+			require.NoError(t, idx.Delete(doc, keys[0]))
+			require.NoError(t, idx.Set(other, keys[0]))
+
+			list, err = tx.Check(true)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			require.Equal(t, database.ReasonMismatchingEntry, list[0].Reason)
+			require.Equal(t, database.ReasonMissingEntry, list[1].Reason)
+
+			list, err = tx.Check(false)
+			require.NoError(t, err)
+			require.Empty(t, list)
+		}
+	})
+}
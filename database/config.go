@@ -11,6 +11,11 @@ import (
 type TableConfig struct {
 	FieldConstraints []FieldConstraint
 
+	// MaxDocumentSize is the maximum size in bytes of an encoded document.
+	// Inserting or replacing a larger document returns an *ErrDocumentTooLarge error.
+	// If zero, the size is unlimited.
+	MaxDocumentSize int
+
 	LastKey int64
 }
 
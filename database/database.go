@@ -2,22 +2,78 @@
 package database
 
 import (
+	"bytes"
+	"sort"
 	"sync"
 
+	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/index"
+	"github.com/pkg/errors"
 )
 
 // A Database manages a list of tables in an engine.
 type Database struct {
-	ng engine.Engine
+	ng       engine.Engine
+	readOnly bool
+	opts     Options
 
 	mu sync.Mutex
+
+	cacheMu      sync.RWMutex
+	caches       map[string]*Cache
+	cacheVersion uint64
 }
 
+// Options of the database.
+type Options struct {
+	// ReadOnly prevents the database from starting read-write transactions:
+	// Begin returns ErrReadOnly if writable is true.
+	// The internal stores are not created when the database is opened,
+	// they must have been created by a previous, writable, instance of the database.
+	// It is the responsibility of the caller to open the engine in read-only mode as well.
+	ReadOnly bool
+
+	// SortBufferSize is the maximum size, in bytes, of the documents buffered in memory
+	// when sorting the results of a query that can't use an index.
+	// When the buffer exceeds that size, its content is sorted and written to a temporary file,
+	// then merged back with the other sorted runs while the results are iterated over.
+	// If zero, DefaultSortBufferSize is used. If negative, all the documents are sorted in memory.
+	SortBufferSize int
+
+	// SortTempDir is the directory where the temporary files used for sorting are created.
+	// If empty, the default directory for temporary files is used (see os.TempDir).
+	SortTempDir string
+}
+
+// DefaultSortBufferSize is the sort buffer size used when Options.SortBufferSize is zero.
+const DefaultSortBufferSize = 64 << 20
+
 // New initializes the DB using the given engine.
 func New(ng engine.Engine) (*Database, error) {
+	return NewWithOptions(ng, Options{})
+}
+
+// NewWithOptions initializes the DB using the given engine and options.
+func NewWithOptions(ng engine.Engine, opts Options) (*Database, error) {
+	if opts.SortBufferSize == 0 {
+		opts.SortBufferSize = DefaultSortBufferSize
+	}
+
 	db := Database{
-		ng: ng,
+		ng:       ng,
+		readOnly: opts.ReadOnly,
+		opts:     opts,
+	}
+
+	if db.readOnly {
+		// make sure the database was initialized
+		tx, err := db.Begin(false)
+		if err != nil {
+			return nil, err
+		}
+
+		return &db, tx.Rollback()
 	}
 
 	ntx, err := db.ng.Begin(true)
@@ -50,6 +106,12 @@ func New(ng engine.Engine) (*Database, error) {
 	return &db, nil
 }
 
+// Options returns the options the database was opened with.
+// SortBufferSize is set to DefaultSortBufferSize if it was zero.
+func (db *Database) Options() Options {
+	return db.opts
+}
+
 // Close the underlying engine.
 func (db *Database) Close() error {
 	return db.ng.Close()
@@ -58,6 +120,10 @@ func (db *Database) Close() error {
 // Begin starts a new transaction.
 // The returned transaction must be closed either by calling Rollback or Commit.
 func (db *Database) Begin(writable bool) (*Transaction, error) {
+	if writable && db.readOnly {
+		return nil, ErrReadOnly
+	}
+
 	ntx, err := db.ng.Begin(writable)
 	if err != nil {
 		return nil, err
@@ -67,6 +133,7 @@ func (db *Database) Begin(writable bool) (*Transaction, error) {
 		db:       db,
 		Tx:       ntx,
 		writable: writable,
+		cache:    db.newTxCache(),
 	}
 
 	tx.tcfgStore, err = tx.getTableConfigStore()
@@ -81,3 +148,598 @@ func (db *Database) Begin(writable bool) (*Transaction, error) {
 
 	return &tx, nil
 }
+
+// TableInfo describes a table of the database.
+type TableInfo struct {
+	Name    string
+	Indexes []IndexConfig
+	// Count is the exact number of documents of the table.
+	Count int
+}
+
+// Tables returns the list of tables of the database, sorted by name, with their indexes
+// and their number of documents, all read from the same read-only transaction.
+// Counting the documents goes through all the keys of each table, see Table.Count.
+func (db *Database) Tables() ([]TableInfo, error) {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	names, err := tx.ListTables()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	infos := make([]TableInfo, 0, len(names))
+	for _, name := range names {
+		tb, err := tx.GetTable(name)
+		if err != nil {
+			return nil, err
+		}
+
+		info := TableInfo{Name: name}
+
+		info.Count, err = tb.Count()
+		if err != nil {
+			return nil, err
+		}
+
+		indexes, err := tb.Indexes()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, idx := range indexes {
+			info.Indexes = append(info.Indexes, IndexConfig{
+				IndexName: idx.IndexName,
+				TableName: idx.TableName,
+				Path:      idx.Path,
+				Unique:    idx.Unique,
+			})
+		}
+		sort.Slice(info.Indexes, func(i, j int) bool {
+			return info.Indexes[i].IndexName < info.Indexes[j].IndexName
+		})
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// DeleteRange deletes all the documents of the selected table whose keys are greater than
+// or equal to min and strictly lesser than max. If min is nil, it starts from the first key,
+// and if max is nil, it goes up to the last one.
+// The range only applies to the primary key: min and max must be encoded like the keys of the table,
+// for example using encoding.EncodeInt64 for an INTEGER primary key, or encoding.EncodeUint64
+// for the generated keys of tables without one. To delete a range of values of any other field,
+// use DeleteIndexRange with an index on that field.
+// To keep memory usage and lock scope bounded, documents are deleted by batches of batchSize
+// documents, each batch being deleted and committed in its own read-write transaction.
+// The operation is therefore not atomic: if an error occurs, the batches already committed
+// are not rolled back, and concurrent transactions may observe a partially deleted range.
+// It returns the number of deleted documents and, if an error occured, the key from which
+// the deletion can be resumed by calling DeleteRange again. The returned key is nil once the
+// whole range has been deleted.
+func (db *Database) DeleteRange(tableName string, min, max []byte, batchSize int) (int, []byte, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	var deleted int
+	keys := make([][]byte, batchSize)
+
+	for {
+		n, err := db.deleteBatch(tableName, min, max, keys)
+		if err != nil {
+			return deleted, min, err
+		}
+		deleted += n
+
+		if n < batchSize {
+			return deleted, nil, nil
+		}
+
+		// the last deleted key doesn't exist anymore, so the next
+		// batch can safely start from it.
+		min = append(min[:0:0], keys[n-1]...)
+	}
+}
+
+func (db *Database) deleteBatch(tableName string, min, max []byte, keys [][]byte) (int, error) {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tb, err := tx.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	// some engines can't delete keys while iterating, so the keys are
+	// copied to a buffer and deleted after the iteration.
+	err = tb.Store.AscendGreaterOrEqual(min, func(k, v []byte) error {
+		if n == len(keys) || (max != nil && bytes.Compare(k, max) >= 0) {
+			return errStop
+		}
+
+		keys[n] = append(keys[n][:0], k...)
+		n++
+		return nil
+	})
+	if err != nil && err != errStop {
+		return 0, err
+	}
+
+	for _, k := range keys[:n] {
+		err = tb.Delete(k)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return n, tx.Commit()
+}
+
+// DeleteIndexRange deletes all the documents of the table of the selected index whose indexed value
+// is greater than or equal to min and strictly lesser than max, in the order of the index.
+// If min is nil, it starts from the first value of the type of max, and if max is nil,
+// it goes up to the last value of the type of min. If both are nil, every document referenced
+// by the index is deleted. Values are compared like in the index, so min and max must
+// be of compatible types: numbers can only be compared to numbers, texts to texts or blobs, etc.
+// Like DeleteRange, documents are deleted by batches of batchSize documents, each batch being
+// deleted and committed in its own read-write transaction. The operation is therefore not atomic:
+// if an error occurs, the batches already committed are not rolled back, and concurrent transactions
+// may observe a partially deleted range. Since the deleted documents are removed from the index,
+// the deletion can be resumed by calling DeleteIndexRange again with the same range.
+// It returns the number of deleted documents.
+func (db *Database) DeleteIndexRange(indexName string, min, max *document.Value, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	var pivot *index.Pivot
+	var maxData []byte
+	switch {
+	case min != nil:
+		pivot = &index.Pivot{Value: *min}
+	case max != nil:
+		pivot = index.MinValue(max.Type)
+	}
+
+	if max != nil {
+		if min != nil && index.NewTypeFromValueType(min.Type) != index.NewTypeFromValueType(max.Type) {
+			return 0, errors.Errorf("can't delete a range between values of type %s and %s", min.Type, max.Type)
+		}
+
+		var err error
+		maxData, err = index.EncodeFieldToIndexValue(*max)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var deleted int
+	keys := make([][]byte, batchSize)
+
+	for {
+		n, err := db.deleteIndexBatch(indexName, pivot, maxData, keys)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+
+		if n < batchSize {
+			return deleted, nil
+		}
+	}
+}
+
+func (db *Database) deleteIndexBatch(indexName string, pivot *index.Pivot, maxData []byte, keys [][]byte) (int, error) {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	idx, err := tx.GetIndex(indexName)
+	if err != nil {
+		return 0, err
+	}
+
+	tb, err := tx.GetTable(idx.TableName)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	// the documents can't be deleted while iterating over the index
+	// they are removed from, so their keys are copied to a buffer first.
+	err = idx.AscendGreaterOrEqual(pivot, func(val document.Value, key []byte) error {
+		if n == len(keys) {
+			return errStop
+		}
+
+		if maxData != nil {
+			data, err := index.EncodeFieldToIndexValue(val)
+			if err != nil {
+				return err
+			}
+			if bytes.Compare(data, maxData) >= 0 {
+				return errStop
+			}
+		}
+
+		keys[n] = append(keys[n][:0], key...)
+		n++
+		return nil
+	})
+	if err != nil && err != errStop {
+		return 0, err
+	}
+
+	for _, k := range keys[:n] {
+		err = tb.Delete(k)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return n, tx.Commit()
+}
+
+// RebuildIndex removes all the entries of the selected index and recreates them from the documents
+// of the table. It can be used to repair an inconsistent index, or to index the documents of a table
+// that were inserted before the index was created.
+// To keep memory usage and lock scope bounded, documents are indexed by batches of batchSize documents,
+// each batch being indexed and committed in its own read-write transaction. The operation is therefore
+// not atomic, and the table should not be modified until it completes.
+// Documents that can't be indexed because their value is already associated with another document
+// of a unique index are not indexed and are returned as discrepancies.
+func (db *Database) RebuildIndex(tableName, indexName string, batchSize int) ([]Discrepancy, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	err := db.truncateIndex(tableName, indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []Discrepancy
+	var last []byte
+
+	for {
+		n, err := db.indexBatch(tableName, indexName, &last, batchSize, &conflicts)
+		if err != nil {
+			return conflicts, err
+		}
+
+		if n < batchSize {
+			return conflicts, nil
+		}
+	}
+}
+
+func (db *Database) truncateIndex(tableName, indexName string) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	idx, err := tx.GetIndex(indexName)
+	if err != nil {
+		return err
+	}
+
+	if idx.TableName != tableName {
+		return ErrIndexNotFound
+	}
+
+	err = idx.Truncate()
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// indexBatch indexes at most batchSize documents whose keys are strictly greater than last,
+// and updates last with the key of the last indexed document.
+func (db *Database) indexBatch(tableName, indexName string, last *[]byte, batchSize int, conflicts *[]Discrepancy) (int, error) {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	idx, err := tx.GetIndex(indexName)
+	if err != nil {
+		return 0, err
+	}
+
+	tb, err := tx.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	// last is updated during the iteration, the pivot must not share its memory.
+	pivot := append([]byte(nil), *last...)
+
+	var n int
+	err = tb.AscendGreaterOrEqual(pivot, func(d document.Document) error {
+		key := d.(document.Keyer).Key()
+		if len(pivot) > 0 && bytes.Equal(key, pivot) {
+			return nil
+		}
+		if n == batchSize {
+			return errStop
+		}
+
+		v, err := idx.Path.GetValue(d)
+		if err != nil && err != document.ErrFieldNotFound {
+			return err
+		}
+		if err == document.ErrFieldNotFound {
+			v = document.NewNullValue()
+		}
+
+		err = idx.Set(v, key)
+		if err == index.ErrDuplicate {
+			*conflicts = append(*conflicts, Discrepancy{
+				TableName: tableName,
+				IndexName: indexName,
+				Key:       append([]byte(nil), key...),
+				Value:     v,
+				Reason:    ReasonDuplicateValue,
+			})
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		*last = append((*last)[:0], key...)
+		n++
+		return nil
+	})
+	if err != nil && err != errStop {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// Compact rewrites all the documents of the selected table into a fresh store and rebuilds
+// its indexes, allowing engines to reclaim the space left by deleted or updated documents.
+// Keys are preserved, as well as the last generated key of the table.
+// To keep memory usage and lock scope bounded, documents are copied by batches of batchSize documents,
+// each batch being copied and committed in its own read-write transaction. Documents are first copied
+// to a temporary store, then copied back to the recreated table store.
+// The operation is therefore not atomic, and the database must not be used until it completes.
+// If it fails after the table store was recreated, the documents are kept in the temporary store
+// and calling Compact again resumes the operation by copying them back.
+// It returns the number of documents rewritten.
+func (db *Database) Compact(tableName string, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	tmpName := compactStorePrefix + tableName
+	doneName := compactDoneStorePrefix + tableName
+
+	done, err := db.storeExists(tableName, doneName)
+	if err != nil {
+		return 0, err
+	}
+
+	// the temporary store only holds a complete copy of the table once the done store
+	// is created. Otherwise, it is the leftover of a compaction that failed before
+	// the table store was recreated, and it can be replaced.
+	if !done {
+		err = db.recreateStore(tableName, tmpName)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = db.copyStore(tableName, tmpName, batchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		err = db.recreateStore(tableName, doneName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = db.recreateStore(tableName, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := db.copyStore(tmpName, tableName, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	indexes, err := db.dropCompactStores(tableName, tmpName, doneName)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, indexName := range indexes {
+		conflicts, err := db.RebuildIndex(tableName, indexName, batchSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(conflicts) > 0 {
+			return 0, errors.Errorf("failed to rebuild index %q: %s", indexName, conflicts[0])
+		}
+	}
+
+	return n, nil
+}
+
+// storeExists returns whether the given store exists.
+// It returns an error if the table doesn't exist.
+func (db *Database) storeExists(tableName, storeName string) (bool, error) {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// ensure the table exists
+	_, err = tx.GetTable(tableName)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Tx.GetStore(storeName)
+	if err == engine.ErrStoreNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// recreateStore drops the given store, if it exists, and creates it again.
+func (db *Database) recreateStore(tableName, storeName string) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ensure the table exists
+	_, err = tx.GetTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Tx.DropStore(storeName)
+	if err != nil && err != engine.ErrStoreNotFound {
+		return err
+	}
+
+	err = tx.Tx.CreateStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	if tx.cache != nil && storeName == tableName {
+		tx.cache.keys[tableName] = nil
+		tx.cache.purged[tableName] = true
+	}
+
+	return tx.Commit()
+}
+
+// copyStore copies all the key value pairs of the src store to the dst store,
+// by batches of batchSize pairs. It returns the number of pairs copied.
+func (db *Database) copyStore(src, dst string, batchSize int) (int, error) {
+	var copied int
+	var last []byte
+
+	for {
+		n, err := db.copyBatch(src, dst, &last, batchSize)
+		if err != nil {
+			return copied, err
+		}
+		copied += n
+
+		if n < batchSize {
+			return copied, nil
+		}
+	}
+}
+
+// copyBatch copies at most batchSize key value pairs whose keys are strictly greater than last,
+// and updates last with the last copied key.
+func (db *Database) copyBatch(src, dst string, last *[]byte, batchSize int) (int, error) {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	srcSt, err := tx.Tx.GetStore(src)
+	if err != nil {
+		return 0, err
+	}
+
+	dstSt, err := tx.Tx.GetStore(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	// last is updated during the iteration, the pivot must not share its memory.
+	pivot := append([]byte(nil), *last...)
+
+	var n int
+	err = srcSt.AscendGreaterOrEqual(pivot, func(k, v []byte) error {
+		if len(pivot) > 0 && bytes.Equal(k, pivot) {
+			return nil
+		}
+		if n == batchSize {
+			return errStop
+		}
+
+		err := dstSt.Put(append([]byte(nil), k...), append([]byte(nil), v...))
+		if err != nil {
+			return err
+		}
+
+		*last = append((*last)[:0], k...)
+		n++
+		return nil
+	})
+	if err != nil && err != errStop {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// dropCompactStores drops the temporary stores used by Compact
+// and returns the names of the indexes of the table.
+func (db *Database) dropCompactStores(tableName string, storeNames ...string) ([]string, error) {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tb, err := tx.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := tb.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		names = append(names, idx.IndexName)
+	}
+	sort.Strings(names)
+
+	for _, name := range storeNames {
+		err = tx.Tx.DropStore(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return names, tx.Commit()
+}
@@ -0,0 +1,467 @@
+package database_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseDeleteRange(t *testing.T) {
+	setup := func(t *testing.T) *database.Database {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_test_a", TableName: "test", Path: []string{"a"}})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			_, err = tb.Insert(document.NewFieldBuffer().
+				Add("id", document.NewInt64Value(int64(i))).
+				Add("a", document.NewInt64Value(int64(i))))
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, tx.Commit())
+		return db
+	}
+
+	count := func(t *testing.T, db *database.Database) (docs int, indexed int) {
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		docs, err = document.NewStream(tb).Count()
+		require.NoError(t, err)
+
+		idx, err := tx.GetIndex("idx_test_a")
+		require.NoError(t, err)
+		err = idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+			indexed++
+			return nil
+		})
+		require.NoError(t, err)
+		return
+	}
+
+	t.Run("Should delete the range by batches", func(t *testing.T) {
+		db := setup(t)
+		defer db.Close()
+
+		n, next, err := db.DeleteRange("test", encoding.EncodeInt64(5), encoding.EncodeInt64(15), 3)
+		require.NoError(t, err)
+		require.Equal(t, 10, n)
+		require.Nil(t, next)
+
+		docs, indexed := count(t, db)
+		require.Equal(t, 10, docs)
+		require.Equal(t, 10, indexed)
+	})
+
+	t.Run("Should delete everything with open bounds", func(t *testing.T) {
+		db := setup(t)
+		defer db.Close()
+
+		n, next, err := db.DeleteRange("test", nil, nil, 7)
+		require.NoError(t, err)
+		require.Equal(t, 20, n)
+		require.Nil(t, next)
+
+		docs, indexed := count(t, db)
+		require.Zero(t, docs)
+		require.Zero(t, indexed)
+	})
+
+	t.Run("Should fail if the table doesn't exist", func(t *testing.T) {
+		db := setup(t)
+		defer db.Close()
+
+		_, _, err := db.DeleteRange("unknown", nil, nil, 10)
+		require.Equal(t, database.ErrTableNotFound, err)
+	})
+
+	t.Run("Should delete a range of indexed values by batches", func(t *testing.T) {
+		db := setup(t)
+		defer db.Close()
+
+		value := func(i int64) *document.Value {
+			v := document.NewInt64Value(i)
+			return &v
+		}
+
+		// 5 <= a < 10
+		n, err := db.DeleteIndexRange("idx_test_a", value(5), value(10), 2)
+		require.NoError(t, err)
+		require.Equal(t, 5, n)
+
+		// a < 3, with a float bound
+		max := document.NewFloat64Value(2.5)
+		n, err = db.DeleteIndexRange("idx_test_a", nil, &max, 2)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+
+		// a >= 17
+		n, err = db.DeleteIndexRange("idx_test_a", value(17), nil, 10)
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+
+		docs, indexed := count(t, db)
+		require.Equal(t, 9, docs)
+		require.Equal(t, 9, indexed)
+
+		// nothing left in that range
+		n, err = db.DeleteIndexRange("idx_test_a", value(5), value(10), 2)
+		require.NoError(t, err)
+		require.Zero(t, n)
+
+		text := document.NewTextValue("a")
+		_, err = db.DeleteIndexRange("idx_test_a", value(0), &text, 2)
+		require.Error(t, err)
+
+		_, err = db.DeleteIndexRange("unknown", nil, nil, 10)
+		require.Equal(t, database.ErrIndexNotFound, err)
+
+		// everything else
+		n, err = db.DeleteIndexRange("idx_test_a", nil, nil, 4)
+		require.NoError(t, err)
+		require.Equal(t, 9, n)
+
+		docs, indexed = count(t, db)
+		require.Zero(t, docs)
+		require.Zero(t, indexed)
+	})
+}
+
+func TestDatabaseRebuildIndex(t *testing.T) {
+	setup := func(t *testing.T, unique bool) *database.Database {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		// insert the documents before creating the index
+		for i := 0; i < 20; i++ {
+			_, err = tb.Insert(document.NewFieldBuffer().
+				Add("id", document.NewInt64Value(int64(i))).
+				Add("a", document.NewInt64Value(int64(i%15))))
+			require.NoError(t, err)
+		}
+
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_test_a", TableName: "test", Path: []string{"a"}, Unique: unique})
+		require.NoError(t, err)
+
+		require.NoError(t, tx.Commit())
+		return db
+	}
+
+	check := func(t *testing.T, db *database.Database) []database.Discrepancy {
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		return list
+	}
+
+	t.Run("Should index all the documents by batches", func(t *testing.T) {
+		db := setup(t, false)
+		defer db.Close()
+
+		require.Len(t, check(t, db), 20)
+
+		conflicts, err := db.RebuildIndex("test", "idx_test_a", 3)
+		require.NoError(t, err)
+		require.Empty(t, conflicts)
+		require.Empty(t, check(t, db))
+	})
+
+	t.Run("Should report duplicates of unique indexes", func(t *testing.T) {
+		db := setup(t, true)
+		defer db.Close()
+
+		conflicts, err := db.RebuildIndex("test", "idx_test_a", 7)
+		require.NoError(t, err)
+		require.Len(t, conflicts, 5)
+		for i, c := range conflicts {
+			require.Equal(t, database.ReasonDuplicateValue, c.Reason)
+			require.Equal(t, encoding.EncodeInt64(int64(15+i)), c.Key)
+			require.Equal(t, document.NewInt64Value(int64(i)), c.Value)
+		}
+	})
+
+	t.Run("Should drop previous null entries", func(t *testing.T) {
+		db := setup(t, true)
+		defer db.Close()
+
+		// documents without the indexed field are indexed as null
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		_, err = tb.Insert(document.NewFieldBuffer().Add("id", document.NewInt64Value(100)))
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		conflicts, err := db.RebuildIndex("test", "idx_test_a", 7)
+		require.NoError(t, err)
+		require.Len(t, conflicts, 5)
+		for _, c := range conflicts {
+			require.NotEqual(t, document.NullValue, c.Value.Type)
+		}
+	})
+
+	t.Run("Should fail if the index doesn't belong to the table", func(t *testing.T) {
+		db := setup(t, false)
+		defer db.Close()
+
+		_, err := db.RebuildIndex("other", "idx_test_a", 10)
+		require.Equal(t, database.ErrIndexNotFound, err)
+
+		_, err = db.RebuildIndex("test", "unknown", 10)
+		require.Equal(t, database.ErrIndexNotFound, err)
+	})
+}
+
+// failingEngine makes writes to the store named failStore fail while fail is true.
+type failingEngine struct {
+	engine.Engine
+	failStore string
+	fail      *bool
+}
+
+func (e failingEngine) Begin(writable bool) (engine.Transaction, error) {
+	tx, err := e.Engine.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+
+	return failingTransaction{tx, e}, nil
+}
+
+type failingTransaction struct {
+	engine.Transaction
+	e failingEngine
+}
+
+func (tx failingTransaction) GetStore(name string) (engine.Store, error) {
+	st, err := tx.Transaction.GetStore(name)
+	if err != nil || name != tx.e.failStore {
+		return st, err
+	}
+
+	return failingStore{st, tx.e.fail}, nil
+}
+
+type failingStore struct {
+	engine.Store
+	fail *bool
+}
+
+func (s failingStore) Put(k, v []byte) error {
+	if *s.fail {
+		return errors.New("injected failure")
+	}
+
+	return s.Store.Put(k, v)
+}
+
+func TestDatabaseCompact(t *testing.T) {
+	setup := func(t *testing.T, fail *bool) *database.Database {
+		db, err := database.New(failingEngine{Engine: memoryengine.NewEngine(), failStore: "test", fail: fail})
+		require.NoError(t, err)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_test_a", TableName: "test", Path: []string{"a"}})
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_test_b", TableName: "test", Path: []string{"b"}, Unique: true})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			fb := document.NewFieldBuffer().Add("a", document.NewInt64Value(int64(i)))
+			// one document is indexed as null by the unique index
+			if i != 1 {
+				fb.Add("b", document.NewInt64Value(int64(i)))
+			}
+
+			key, err := tb.Insert(fb)
+			require.NoError(t, err)
+
+			if i%2 == 0 {
+				require.NoError(t, tb.Delete(key))
+			}
+		}
+
+		require.NoError(t, tx.Commit())
+		return db
+	}
+
+	check := func(t *testing.T, db *database.Database) {
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tables, err := tx.ListTables()
+		require.NoError(t, err)
+		require.Equal(t, []string{"test"}, tables)
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		require.Empty(t, list)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		var i int64
+		err = tb.Iterate(func(d document.Document) error {
+			require.Equal(t, encoding.EncodeInt64(2*i+2), d.(document.Keyer).Key())
+			v, err := d.GetByField("a")
+			require.NoError(t, err)
+			require.Equal(t, document.NewInt64Value(2*i+1), v)
+			i++
+			return nil
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, 10, i)
+
+		// the last generated key must be preserved
+		key, err := tb.Insert(document.NewFieldBuffer().
+			Add("a", document.NewInt64Value(20)).
+			Add("b", document.NewInt64Value(20)))
+		require.NoError(t, err)
+		require.Equal(t, encoding.EncodeInt64(21), key)
+	}
+
+	t.Run("Should rewrite the table", func(t *testing.T) {
+		var fail bool
+		db := setup(t, &fail)
+		defer db.Close()
+
+		n, err := db.Compact("test", 3)
+		require.NoError(t, err)
+		require.Equal(t, 10, n)
+
+		_, err = db.Compact("unknown", 3)
+		require.Equal(t, database.ErrTableNotFound, err)
+
+		check(t, db)
+	})
+
+	t.Run("Should resume after a failure", func(t *testing.T) {
+		var fail bool
+		db := setup(t, &fail)
+		defer db.Close()
+
+		// fail while copying the documents back to the table store
+		fail = true
+		_, err := db.Compact("test", 3)
+		require.EqualError(t, err, "injected failure")
+		fail = false
+
+		// the documents are only kept in the temporary store
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		err = tb.Iterate(func(d document.Document) error {
+			return errors.New("the table store should be empty")
+		})
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+
+		n, err := db.Compact("test", 3)
+		require.NoError(t, err)
+		require.Equal(t, 10, n)
+
+		check(t, db)
+	})
+}
+
+func TestDatabaseTables(t *testing.T) {
+	db, err := database.New(memoryengine.NewEngine())
+	require.NoError(t, err)
+	defer db.Close()
+
+	infos, err := db.Tables()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+
+	err = func() error {
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		for _, name := range []string{"foo", "bar"} {
+			err = tx.CreateTable(name, nil)
+			require.NoError(t, err)
+		}
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_foo_b", TableName: "foo", Path: []string{"b"}})
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_foo_a", TableName: "foo", Path: []string{"a"}, Unique: true})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("foo")
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err = tb.Insert(document.NewFieldBuffer().Add("a", document.NewIntValue(i)))
+			require.NoError(t, err)
+		}
+
+		// raw values are not documents
+		err = tb.PutRaw([]byte("k"), []byte("v"))
+		require.NoError(t, err)
+
+		return tx.Commit()
+	}()
+	require.NoError(t, err)
+
+	infos, err = db.Tables()
+	require.NoError(t, err)
+	require.Equal(t, []database.TableInfo{
+		{Name: "bar"},
+		{Name: "foo", Count: 3, Indexes: []database.IndexConfig{
+			{IndexName: "idx_foo_a", TableName: "foo", Path: []string{"a"}, Unique: true},
+			{IndexName: "idx_foo_b", TableName: "foo", Path: []string{"b"}},
+		}},
+	}, infos)
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -25,4 +26,19 @@ var (
 	// ErrDuplicateDocument is returned when another document is already associated with a given key, primary key,
 	// or if there is a unique index violation.
 	ErrDuplicateDocument = errors.New("duplicate document")
+
+	// ErrReadOnly is returned when attempting to start a read-write transaction
+	// on a database opened in read-only mode.
+	ErrReadOnly = errors.New("database is read-only")
 )
+
+// ErrDocumentTooLarge is returned when the encoded size of a document exceeds the
+// maximum document size of its table.
+type ErrDocumentTooLarge struct {
+	Size  int
+	Limit int
+}
+
+func (e *ErrDocumentTooLarge) Error() string {
+	return fmt.Sprintf("document too large: %d bytes, limit is %d bytes", e.Size, e.Limit)
+}
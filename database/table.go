@@ -3,6 +3,8 @@ package database
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 
 	"github.com/asdine/genji/document"
@@ -54,8 +56,62 @@ func (t *Table) Iterate(fn func(d document.Document) error) error {
 	})
 }
 
+// AscendGreaterOrEqual seeks for the pivot key and then goes through all the subsequent documents
+// of the table in increasing key order, calling fn for each one of them.
+// If the pivot is nil, starts from the beginning.
+// It can be used to resume an iteration from a known key.
+// If the given function returns an error, the iteration stops.
+func (t *Table) AscendGreaterOrEqual(pivot []byte, fn func(d document.Document) error) error {
+	var d encodedDocumentWithKey
+
+	return t.Store.AscendGreaterOrEqual(pivot, func(k, v []byte) error {
+		d.EncodedDocument = v
+		d.key = k
+		return fn(&d)
+	})
+}
+
+// DescendLessOrEqual seeks for the pivot key and then goes through all the subsequent documents
+// of the table in decreasing key order, calling fn for each one of them.
+// If the pivot is nil, starts from the end.
+// It can be used to resume a reverse iteration from a known key.
+// If the given function returns an error, the iteration stops.
+func (t *Table) DescendLessOrEqual(pivot []byte, fn func(d document.Document) error) error {
+	var d encodedDocumentWithKey
+
+	return t.Store.DescendLessOrEqual(pivot, func(k, v []byte) error {
+		d.EncodedDocument = v
+		d.key = k
+		return fn(&d)
+	})
+}
+
+// Reverse returns an iterator that goes through all the documents of the table
+// in decreasing key order.
+// It implements the document.ReversibleIterator interface.
+func (t *Table) Reverse() document.Iterator {
+	return reverseTableIterator{t}
+}
+
+type reverseTableIterator struct {
+	t *Table
+}
+
+func (it reverseTableIterator) Iterate(fn func(d document.Document) error) error {
+	return it.t.DescendLessOrEqual(nil, fn)
+}
+
 // GetDocument returns one document by key.
+// If the table has a cache, the document is looked up in the cache first.
 func (t *Table) GetDocument(key []byte) (document.Document, error) {
+	c := t.cache()
+	if c != nil {
+		d, ok := t.tx.db.cacheGet(c, t.tx.cache.version, key)
+		if ok {
+			return d, nil
+		}
+	}
+
 	v, err := t.Store.Get(key)
 	if err != nil {
 		if err == engine.ErrKeyNotFound {
@@ -64,12 +120,134 @@ func (t *Table) GetDocument(key []byte) (document.Document, error) {
 		return nil, errors.Wrapf(err, "failed to fetch document %q", key)
 	}
 
+	if c != nil {
+		err = t.tx.db.cacheAdd(c, t.tx.cache.version, key, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var d encodedDocumentWithKey
 	d.EncodedDocument = encoding.EncodedDocument(v)
 	d.key = key
 	return &d, err
 }
 
+// GetDocuments returns the documents associated with the given keys, in the same order.
+// Keys are looked up in increasing order to benefit from the locality of the underlying store.
+// If a key doesn't exist, the document at the same position is nil.
+func (t *Table) GetDocuments(keys [][]byte) ([]document.Document, error) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	docs := make([]document.Document, len(keys))
+	for _, i := range order {
+		d, err := t.GetDocument(keys[i])
+		if err == ErrDocumentNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		docs[i] = d
+	}
+
+	return docs, nil
+}
+
+// cache returns the cache of the table, if any, unless the table was modified
+// by the current transaction.
+func (t *Table) cache() *Cache {
+	if t.tx.cache == nil || t.tx.cache.modified(t.name) {
+		return nil
+	}
+
+	return t.tx.db.getCache(t.name)
+}
+
+// markModified records that the document associated with the key was modified by the
+// current transaction, so that it can be removed from the table cache on commit.
+func (t *Table) markModified(key []byte) {
+	if t.tx.cache == nil || t.tx.db.getCache(t.name) == nil {
+		return
+	}
+
+	t.tx.cache.keys[t.name] = append(t.tx.cache.keys[t.name], string(key))
+}
+
+// Exists returns true if a document is associated with the given key.
+// The document is not decoded.
+func (t *Table) Exists(key []byte) (bool, error) {
+	_, err := t.Store.Get(key)
+	if err == engine.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+var errStop = errors.New("stop")
+
+// ExistsByField returns true if at least one document has a field at path p equal to v.
+// The field must either be the primary key or be indexed, so that the lookup
+// never requires a table scan. Otherwise an error is returned.
+func (t *Table) ExistsByField(p document.ValuePath, v document.Value) (bool, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return false, err
+	}
+
+	if pk := cfg.GetPrimaryKey(); pk != nil && pk.Path.String() == p.String() {
+		if pk.Type != 0 {
+			v, err = v.ConvertTo(pk.Type)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		key, err := encoding.EncodeValue(v)
+		if err != nil {
+			return false, err
+		}
+
+		return t.Exists(key)
+	}
+
+	indexes, err := t.Indexes()
+	if err != nil {
+		return false, err
+	}
+
+	idx, ok := indexes[p.String()]
+	if !ok {
+		return false, fmt.Errorf("no index found for field %q", p)
+	}
+
+	var found bool
+	err = idx.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
+		found, err = v.IsEqual(val)
+		if err != nil {
+			return err
+		}
+
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return false, err
+	}
+
+	return found, nil
+}
+
 func (t *Table) generateKey(d document.Document) ([]byte, error) {
 	cfg, err := t.cfgStore.Get(t.name)
 	if err != nil {
@@ -255,54 +433,176 @@ func validateConstraint(d document.Document, c *FieldConstraint) error {
 // If a primary key has been specified during the table creation, the field is expected to be present
 // in the given document.
 // If no primary key has been selected, a monotonic autoincremented integer key will be generated.
+// If the table has no field constraints, a document already encoded with encoding.EncodeDocument
+// and passed as an encoding.EncodedDocument is stored as is, without being encoded again.
+// Only its indexed fields are decoded.
 func (t *Table) Insert(d document.Document) ([]byte, error) {
-	d, err := t.validateConstraints(d)
+	key, _, err := t.insert(d)
+	return key, err
+}
+
+// InsertReturning inserts a document like Insert and returns the document as it was stored,
+// after its fields were converted to the types required by the table constraints.
+// The returned document implements the document.Keyer interface and
+// is only valid for the lifetime of the transaction.
+func (t *Table) InsertReturning(d document.Document) (document.Document, error) {
+	key, v, err := t.insert(d)
 	if err != nil {
 		return nil, err
 	}
 
+	return &encodedDocumentWithKey{
+		EncodedDocument: v,
+		key:             key,
+	}, nil
+}
+
+// insert stores the document and returns its key and its encoded form.
+func (t *Table) insert(d document.Document) ([]byte, []byte, error) {
+	d, key, v, err := t.store(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indexes, err := t.Indexes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, idx := range indexes {
+		v, err := idx.Path.GetValue(d)
+		if err != nil {
+			v = document.NewNullValue()
+		}
+
+		err = idx.Set(v, key)
+		if err != nil {
+			if err == index.ErrDuplicate {
+				return nil, nil, ErrDuplicateDocument
+			}
+
+			return nil, nil, err
+		}
+	}
+
+	return key, v, nil
+}
+
+// store validates the document, generates its key and stores it in the table, without indexing it.
+// It returns the validated document, its key and its encoded form.
+func (t *Table) store(d document.Document) (document.Document, []byte, []byte, error) {
+	d, err := t.validateConstraints(d)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	v, err := encoding.EncodeDocument(d)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to encode document")
+	}
+
+	// the size is checked before generating the key,
+	// which updates the table configuration of tables without primary key
+	err = t.checkSize(v)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	key, err := t.generateKey(d)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	_, err = t.Store.Get(key)
 	if err == nil {
-		return nil, ErrDuplicateDocument
+		return nil, nil, nil, ErrDuplicateDocument
 	}
 
-	v, err := encoding.EncodeDocument(d)
+	err = t.Store.Put(key, v)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to encode document")
+		return nil, nil, nil, err
 	}
+	t.markModified(key)
 
-	err = t.Store.Put(key, v)
+	return d, key, v, nil
+}
+
+// checkSize returns an error if the encoded document exceeds the maximum document size of the table.
+func (t *Table) checkSize(data []byte) error {
+	cfg, err := t.Config()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if cfg.MaxDocumentSize > 0 && len(data) > cfg.MaxDocumentSize {
+		return &ErrDocumentTooLarge{Size: len(data), Limit: cfg.MaxDocumentSize}
 	}
 
+	return nil
+}
+
+// InsertBatch inserts the documents like Insert and returns their keys, in the same order.
+// Indexes are loaded once for the whole batch and index entries are written after all the documents
+// have been stored, sorted by value, so that each index is written sequentially.
+// If an error is returned, some of the documents may have been inserted, and the transaction
+// should be rolled back.
+func (t *Table) InsertBatch(docs []document.Document) ([][]byte, error) {
 	indexes, err := t.Indexes()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, idx := range indexes {
-		v, err := idx.Path.GetValue(d)
+	type indexEntry struct {
+		enc []byte
+		v   document.Value
+		key []byte
+	}
+	entries := make(map[string][]indexEntry, len(indexes))
+
+	keys := make([][]byte, len(docs))
+	for i, d := range docs {
+		d, key, _, err := t.store(d)
 		if err != nil {
-			v = document.NewNullValue()
+			return nil, err
 		}
+		keys[i] = key
 
-		err = idx.Set(v, key)
-		if err != nil {
-			if err == index.ErrDuplicate {
-				return nil, ErrDuplicateDocument
+		for name, idx := range indexes {
+			v, err := idx.Path.GetValue(d)
+			if err != nil {
+				v = document.NewNullValue()
 			}
 
-			return nil, err
+			enc, err := index.EncodeFieldToIndexValue(v)
+			if err != nil {
+				return nil, err
+			}
+
+			// values of different index types are stored separately, in increasing type order
+			enc = append([]byte{byte(index.NewTypeFromValueType(v.Type))}, enc...)
+			entries[name] = append(entries[name], indexEntry{enc: enc, v: v, key: key})
 		}
 	}
 
-	return key, nil
+	for name, idx := range indexes {
+		list := entries[name]
+		sort.SliceStable(list, func(i, j int) bool {
+			return bytes.Compare(list[i].enc, list[j].enc) < 0
+		})
+
+		for _, e := range list {
+			err = idx.Set(e.v, e.key)
+			if err != nil {
+				if err == index.ErrDuplicate {
+					return nil, ErrDuplicateDocument
+				}
+
+				return nil, err
+			}
+		}
+	}
+
+	return keys, nil
 }
 
 // Delete a document by key.
@@ -330,7 +630,13 @@ func (t *Table) Delete(key []byte) error {
 		}
 	}
 
-	return t.Store.Delete(key)
+	err = t.Store.Delete(key)
+	if err != nil {
+		return err
+	}
+	t.markModified(key)
+
+	return nil
 }
 
 // Replace a document by key.
@@ -345,6 +651,73 @@ func (t *Table) Replace(key []byte, d document.Document) error {
 	return t.replace(indexes, key, d)
 }
 
+// IncrementField adds delta to the integer field of the document associated with the key,
+// stores the document and returns the new value of the field.
+// The read and the write happen in the transaction of the table, which makes the
+// increment atomic.
+// If the document doesn't have the field, the field is created with delta as its value.
+// The field keeps its type: if the new value doesn't fit, an error is returned.
+// It returns an error if the field is not an integer or if it is the primary key.
+func (t *Table) IncrementField(key []byte, field string, delta int64) (int64, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return 0, err
+	}
+
+	if pk := cfg.GetPrimaryKey(); pk != nil && pk.Path.String() == field {
+		return 0, fmt.Errorf("cannot increment primary key %q", field)
+	}
+
+	d, err := t.GetDocument(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var fb document.FieldBuffer
+	err = fb.Copy(d)
+	if err != nil {
+		return 0, err
+	}
+
+	var v document.Value
+	cur, err := fb.GetByField(field)
+	switch err {
+	case document.ErrFieldNotFound:
+		v, err = document.NewValue(delta)
+	case nil:
+		if cur.Type < document.Int8Value || cur.Type > document.Int64Value {
+			return 0, fmt.Errorf("cannot increment field %q of type %s", field, cur.Type)
+		}
+
+		var x int64
+		x, err = cur.ConvertToInt64()
+		if err != nil {
+			return 0, err
+		}
+
+		if (delta > 0 && x > math.MaxInt64-delta) || (delta < 0 && x < math.MinInt64-delta) {
+			return 0, fmt.Errorf("cannot increment field %q: out of range", field)
+		}
+
+		v, err = document.NewInt64Value(x + delta).ConvertTo(cur.Type)
+		if err != nil {
+			return 0, fmt.Errorf("cannot increment field %q: out of range", field)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	fb.Set(field, v)
+
+	err = t.Replace(key, &fb)
+	if err != nil {
+		return 0, err
+	}
+
+	return v.ConvertToInt64()
+}
+
 func (t *Table) replace(indexes map[string]Index, key []byte, d document.Document) error {
 	// make sure key exists
 	old, err := t.GetDocument(key)
@@ -352,6 +725,17 @@ func (t *Table) replace(indexes map[string]Index, key []byte, d document.Documen
 		return err
 	}
 
+	// encode new document
+	v, err := encoding.EncodeDocument(d)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode document")
+	}
+
+	err = t.checkSize(v)
+	if err != nil {
+		return err
+	}
+
 	// remove key from indexes
 	for _, idx := range indexes {
 		v, err := idx.Path.GetValue(old)
@@ -365,17 +749,12 @@ func (t *Table) replace(indexes map[string]Index, key []byte, d document.Documen
 		}
 	}
 
-	// encode new document
-	v, err := encoding.EncodeDocument(d)
-	if err != nil {
-		return errors.Wrap(err, "failed to encode document")
-	}
-
 	// replace old document with new document
 	err = t.Store.Put(key, v)
 	if err != nil {
 		return err
 	}
+	t.markModified(key)
 
 	// update indexes
 	for _, idx := range indexes {
@@ -393,9 +772,80 @@ func (t *Table) replace(indexes map[string]Index, key []byte, d document.Documen
 	return err
 }
 
+// PutRaw associates an opaque value with the given key.
+// Raw values are stored apart from the documents of the table: they are not indexed,
+// not subject to field constraints and invisible to the structured query API.
+// They are only accessible using GetRaw and are deleted when the table is dropped.
+func (t *Table) PutRaw(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return errors.New("empty key")
+	}
+
+	name := rawStorePrefix + t.name
+	st, err := t.tx.Tx.GetStore(name)
+	if err == engine.ErrStoreNotFound {
+		err = t.tx.Tx.CreateStore(name)
+		if err != nil {
+			return err
+		}
+		st, err = t.tx.Tx.GetStore(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return st.Put(key, value)
+}
+
+// GetRaw returns the value associated with the given key using PutRaw.
+// If no value is associated with the key, it returns ErrDocumentNotFound.
+func (t *Table) GetRaw(key []byte) ([]byte, error) {
+	st, err := t.tx.Tx.GetStore(rawStorePrefix + t.name)
+	if err == engine.ErrStoreNotFound {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := st.Get(key)
+	if err == engine.ErrKeyNotFound {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch raw value %q", key)
+	}
+
+	return v, nil
+}
+
 // Truncate deletes all the documents from the table.
 func (t *Table) Truncate() error {
-	return t.Store.Truncate()
+	err := t.Store.Truncate()
+	if err != nil {
+		return err
+	}
+
+	if t.tx.cache != nil {
+		t.tx.cache.keys[t.name] = nil
+		t.tx.cache.purged[t.name] = true
+	}
+
+	return nil
+}
+
+// Count returns the number of documents of the table.
+// The count is exact but it is O(n): it goes through every key-value pair of the table
+// without decoding the documents, so its cost grows with the size of the table and,
+// depending on the engine, reading the values of the pairs can still hit the disk.
+// No counter is maintained: callers that need the count often should cache it.
+func (t *Table) Count() (int, error) {
+	var n int
+	err := t.Store.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		n++
+		return nil
+	})
+	return n, err
 }
 
 // TableName returns the name of the table.
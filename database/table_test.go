@@ -3,6 +3,7 @@ package database_test
 import (
 	"errors"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/asdine/genji/database"
@@ -69,6 +70,93 @@ func TestTableIterate(t *testing.T) {
 	})
 }
 
+func TestTableReverse(t *testing.T) {
+	tb, cleanup := newTestTable(t)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		_, err := tb.Insert(newDocument())
+		require.NoError(t, err)
+	}
+
+	var keys, reversed [][]byte
+	err := tb.Iterate(func(d document.Document) error {
+		keys = append(keys, append([]byte{}, d.(document.Keyer).Key()...))
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = tb.Reverse().Iterate(func(d document.Document) error {
+		reversed = append([][]byte{append([]byte{}, d.(document.Keyer).Key()...)}, reversed...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, keys, reversed)
+
+	d, err := document.NewStream(tb).Last()
+	require.NoError(t, err)
+	require.Equal(t, keys[len(keys)-1], d.(document.Keyer).Key())
+}
+
+// TestTableAscendDescend verifies AscendGreaterOrEqual and DescendLessOrEqual behaviour.
+func TestTableAscendDescend(t *testing.T) {
+	insert := func(t *testing.T, tb *database.Table) [][]byte {
+		var keys [][]byte
+		for i := 0; i < 10; i++ {
+			key, err := tb.Insert(newDocument())
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	t.Run("Should iterate from the pivot in increasing order", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		keys := insert(t, tb)
+
+		var got [][]byte
+		err := tb.AscendGreaterOrEqual(keys[5], func(d document.Document) error {
+			got = append(got, append([]byte{}, d.(document.Keyer).Key()...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, keys[5:], got)
+	})
+
+	t.Run("Should iterate from the pivot in decreasing order", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		keys := insert(t, tb)
+
+		var got [][]byte
+		err := tb.DescendLessOrEqual(keys[5], func(d document.Document) error {
+			got = append(got, append([]byte{}, d.(document.Keyer).Key()...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, got, 6)
+		for i := range got {
+			require.Equal(t, keys[5-i], got[i])
+		}
+	})
+
+	t.Run("Should not iterate if no key is greater than the pivot", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		keys := insert(t, tb)
+
+		pivot := append(append([]byte{}, keys[9]...), 0xFF)
+		err := tb.AscendGreaterOrEqual(pivot, func(d document.Document) error {
+			return errors.New("should not iterate")
+		})
+		require.NoError(t, err)
+	})
+}
+
 // TestTableGetDocument verifies GetDocument behaviour.
 func TestTableGetDocument(t *testing.T) {
 	t.Run("Should fail if not found", func(t *testing.T) {
@@ -104,6 +192,120 @@ func TestTableGetDocument(t *testing.T) {
 	})
 }
 
+func TestTableGetDocuments(t *testing.T) {
+	tb, cleanup := newTestTable(t)
+	defer cleanup()
+
+	var keys [][]byte
+	for i := 0; i < 5; i++ {
+		key, err := tb.Insert(document.NewFieldBuffer().Add("a", document.NewInt64Value(int64(i))))
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	docs, err := tb.GetDocuments([][]byte{keys[3], []byte("unknown"), keys[0], keys[4], keys[0]})
+	require.NoError(t, err)
+	require.Len(t, docs, 5)
+	require.Nil(t, docs[1])
+
+	for i, expected := range []int64{3, -1, 0, 4, 0} {
+		if expected < 0 {
+			continue
+		}
+
+		v, err := docs[i].GetByField("a")
+		require.NoError(t, err)
+		require.Equal(t, document.NewInt64Value(expected), v)
+	}
+
+	docs, err = tb.GetDocuments(nil)
+	require.NoError(t, err)
+	require.Empty(t, docs)
+}
+
+// TestTableExists verifies Exists and ExistsByField behaviour.
+func TestTableExists(t *testing.T) {
+	t.Run("Should return true if the key exists", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		key, err := tb.Insert(newDocument())
+		require.NoError(t, err)
+
+		ok, err := tb.Exists(key)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = tb.Exists([]byte("unknown"))
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("Should lookup the primary key", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		_, err = tb.Insert(document.NewFieldBuffer().Add("id", document.NewInt64Value(10)))
+		require.NoError(t, err)
+
+		ok, err := tb.ExistsByField(document.NewValuePath("id"), document.NewIntValue(10))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = tb.ExistsByField(document.NewValuePath("id"), document.NewIntValue(11))
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("Should lookup indexed fields", func(t *testing.T) {
+		for _, unique := range []bool{true, false} {
+			tx, cleanup := newTestDB(t)
+			defer cleanup()
+
+			err := tx.CreateTable("test", nil)
+			require.NoError(t, err)
+			err = tx.CreateIndex(database.IndexConfig{
+				IndexName: "idx_test_fielda", TableName: "test", Path: []string{"fielda"}, Unique: unique,
+			})
+			require.NoError(t, err)
+			tb, err := tx.GetTable("test")
+			require.NoError(t, err)
+
+			_, err = tb.Insert(newDocument())
+			require.NoError(t, err)
+
+			ok, err := tb.ExistsByField(document.NewValuePath("fielda"), document.NewTextValue("a"))
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			ok, err = tb.ExistsByField(document.NewValuePath("fielda"), document.NewTextValue("b"))
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			ok, err = tb.ExistsByField(document.NewValuePath("fielda"), document.NewTextValue(""))
+			require.NoError(t, err)
+			require.False(t, ok)
+		}
+	})
+
+	t.Run("Should fail if the field is not indexed", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		_, err := tb.ExistsByField(document.NewValuePath("fielda"), document.NewTextValue("a"))
+		require.Error(t, err)
+	})
+}
+
 // TestTableInsert verifies Insert behaviour.
 func TestTableInsert(t *testing.T) {
 	t.Run("Should generate a key by default", func(t *testing.T) {
@@ -153,6 +355,40 @@ func TestTableInsert(t *testing.T) {
 		require.Equal(t, database.ErrDuplicateDocument, err)
 	})
 
+	t.Run("Should use uuids as primary keys", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: []string{"id"}, Type: document.UUIDValue, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		ids := []string{"017f22e2-79b1-7000-8000-000000000000", "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"}
+		for _, id := range ids {
+			key, err := tb.Insert(document.NewFieldBuffer().Add("id", document.NewTextValue(id)))
+			require.NoError(t, err)
+			require.Len(t, key, 16)
+		}
+
+		var got []string
+		err = tb.Iterate(func(d document.Document) error {
+			v, err := d.GetByField("id")
+			if err != nil {
+				return err
+			}
+			require.Equal(t, document.UUIDValue, v.Type)
+			got = append(got, v.String())
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{ids[1], ids[0]}, got)
+	})
+
 	t.Run("Should convert values into the right types if there are constraints", func(t *testing.T) {
 		tx, cleanup := newTestDB(t)
 		defer cleanup()
@@ -359,6 +595,239 @@ func TestTableInsert(t *testing.T) {
 	})
 }
 
+func TestTableInsertEncoded(t *testing.T) {
+	tx, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := tx.CreateTable("test", nil)
+	require.NoError(t, err)
+	err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_foo", TableName: "test", Path: []string{"foo"}})
+	require.NoError(t, err)
+	tb, err := tx.GetTable("test")
+	require.NoError(t, err)
+
+	data, err := encoding.EncodeDocument(document.NewFieldBuffer().
+		Add("foo", document.NewIntValue(10)).
+		Add("bar", document.NewTextValue("baz")))
+	require.NoError(t, err)
+
+	// the same encoded document can be inserted multiple times
+	for i := 0; i < 3; i++ {
+		key, err := tb.Insert(encoding.EncodedDocument(data))
+		require.NoError(t, err)
+
+		stored, err := tb.Store.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, data, stored)
+	}
+
+	idx, err := tx.GetIndex("idx_foo")
+	require.NoError(t, err)
+
+	var count int
+	err = idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+}
+
+func TestTableInsertBatch(t *testing.T) {
+	setup := func(t *testing.T) (*database.Transaction, *database.Table, func()) {
+		tx, cleanup := newTestDB(t)
+
+		err := tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_a", TableName: "test", Path: []string{"a"}})
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_b", TableName: "test", Path: []string{"b"}, Unique: true})
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		return tx, tb, cleanup
+	}
+
+	t.Run("Should insert and index all the documents", func(t *testing.T) {
+		tx, tb, cleanup := setup(t)
+		defer cleanup()
+
+		docs := []document.Document{
+			document.NewFieldBuffer().Add("a", document.NewInt64Value(3)).Add("b", document.NewTextValue("x")),
+			document.NewFieldBuffer().Add("a", document.NewTextValue("foo")),
+			document.NewFieldBuffer().Add("a", document.NewInt64Value(1)).Add("b", document.NewTextValue("y")),
+		}
+
+		keys, err := tb.InsertBatch(docs)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{encoding.EncodeInt64(1), encoding.EncodeInt64(2), encoding.EncodeInt64(3)}, keys)
+
+		list, err := tx.Check(false)
+		require.NoError(t, err)
+		require.Empty(t, list)
+
+		idx, err := tx.GetIndex("idx_a")
+		require.NoError(t, err)
+
+		var indexed [][]byte
+		err = idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+			indexed = append(indexed, append([]byte(nil), key...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{keys[2], keys[0], keys[1]}, indexed)
+	})
+
+	t.Run("Should fail on duplicates", func(t *testing.T) {
+		_, tb, cleanup := setup(t)
+		defer cleanup()
+
+		_, err := tb.InsertBatch([]document.Document{
+			document.NewFieldBuffer().Add("b", document.NewTextValue("x")),
+			document.NewFieldBuffer().Add("b", document.NewTextValue("x")),
+		})
+		require.Equal(t, database.ErrDuplicateDocument, err)
+	})
+}
+
+func TestTableMaxDocumentSize(t *testing.T) {
+	tx, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := tx.CreateTable("test", &database.TableConfig{MaxDocumentSize: 100})
+	require.NoError(t, err)
+	err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_a", TableName: "test", Path: []string{"a"}})
+	require.NoError(t, err)
+	tb, err := tx.GetTable("test")
+	require.NoError(t, err)
+
+	small := document.NewFieldBuffer().Add("a", document.NewBlobValue(make([]byte, 10)))
+	large := document.NewFieldBuffer().Add("a", document.NewBlobValue(make([]byte, 200)))
+
+	key, err := tb.Insert(small)
+	require.NoError(t, err)
+
+	_, err = tb.Insert(large)
+	require.IsType(t, &database.ErrDocumentTooLarge{}, err)
+	require.Equal(t, 100, err.(*database.ErrDocumentTooLarge).Limit)
+	require.True(t, err.(*database.ErrDocumentTooLarge).Size > 200)
+
+	// no key must have been generated
+	cfg, err := tb.Config()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, cfg.LastKey)
+
+	err = tb.Replace(key, large)
+	require.IsType(t, &database.ErrDocumentTooLarge{}, err)
+
+	// nothing must have been written
+	var count int
+	err = tb.Iterate(func(d document.Document) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	list, err := tx.Check(false)
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func TestTableInsertReturning(t *testing.T) {
+	tx, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := tx.CreateTable("test", &database.TableConfig{
+		FieldConstraints: []database.FieldConstraint{
+			{Path: []string{"foo"}, Type: document.Int32Value},
+		},
+	})
+	require.NoError(t, err)
+	tb, err := tx.GetTable("test")
+	require.NoError(t, err)
+
+	d, err := tb.InsertReturning(document.NewFieldBuffer().Add("foo", document.NewFloat64Value(100)))
+	require.NoError(t, err)
+	require.Equal(t, encoding.EncodeInt64(1), d.(document.Keyer).Key())
+
+	v, err := d.GetByField("foo")
+	require.NoError(t, err)
+	require.Equal(t, document.NewInt32Value(100), v)
+
+	_, err = tb.InsertReturning(document.NewFieldBuffer().Add("foo", document.NewTextValue("bar")))
+	require.Error(t, err)
+}
+
+func TestTableIncrementField(t *testing.T) {
+	tx, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := tx.CreateTable("test", &database.TableConfig{
+		FieldConstraints: []database.FieldConstraint{
+			{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+		},
+	})
+	require.NoError(t, err)
+	err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_n", TableName: "test", Path: []string{"n"}})
+	require.NoError(t, err)
+	tb, err := tx.GetTable("test")
+	require.NoError(t, err)
+
+	key, err := tb.Insert(document.NewFieldBuffer().
+		Add("id", document.NewInt64Value(1)).
+		Add("n", document.NewInt8Value(10)).
+		Add("s", document.NewTextValue("foo")))
+	require.NoError(t, err)
+
+	n, err := tb.IncrementField(key, "n", 5)
+	require.NoError(t, err)
+	require.EqualValues(t, 15, n)
+
+	n, err = tb.IncrementField(key, "n", -20)
+	require.NoError(t, err)
+	require.EqualValues(t, -5, n)
+
+	d, err := tb.GetDocument(key)
+	require.NoError(t, err)
+	v, err := d.GetByField("n")
+	require.NoError(t, err)
+	require.Equal(t, document.NewInt8Value(-5), v)
+
+	// the index must be updated
+	list, err := tx.Check(false)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	// missing fields are initialized
+	n, err = tb.IncrementField(key, "m", 3)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+
+	// the field keeps its type
+	_, err = tb.IncrementField(key, "n", 1000)
+	require.EqualError(t, err, `cannot increment field "n": out of range`)
+
+	n, err = tb.IncrementField(key, "n", 132)
+	require.NoError(t, err)
+	require.EqualValues(t, math.MaxInt8, n)
+	_, err = tb.IncrementField(key, "n", 1)
+	require.EqualError(t, err, `cannot increment field "n": out of range`)
+
+	_, err = tb.IncrementField(key, "m", math.MaxInt64)
+	require.EqualError(t, err, `cannot increment field "m": out of range`)
+
+	_, err = tb.IncrementField(key, "s", 1)
+	require.EqualError(t, err, `cannot increment field "s" of type text`)
+
+	_, err = tb.IncrementField(key, "id", 1)
+	require.EqualError(t, err, `cannot increment primary key "id"`)
+
+	_, err = tb.IncrementField([]byte("unknown"), "n", 1)
+	require.Equal(t, database.ErrDocumentNotFound, err)
+}
+
 // TestTableDelete verifies Delete behaviour.
 func TestTableDelete(t *testing.T) {
 	t.Run("Should fail if not found", func(t *testing.T) {
@@ -483,6 +952,78 @@ func TestTableTruncate(t *testing.T) {
 	})
 }
 
+func TestTableRaw(t *testing.T) {
+	t.Run("Should return ErrDocumentNotFound if the key doesn't exist", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		_, err := tb.GetRaw([]byte("foo"))
+		require.Equal(t, database.ErrDocumentNotFound, err)
+
+		require.NoError(t, tb.PutRaw([]byte("foo"), []byte("bar")))
+		_, err = tb.GetRaw([]byte("baz"))
+		require.Equal(t, database.ErrDocumentNotFound, err)
+	})
+
+	t.Run("Should store raw values apart from documents", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idxFoo", TableName: "test", Path: document.NewValuePath("foo"),
+		})
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		require.NoError(t, tb.PutRaw([]byte("foo"), []byte("bar")))
+		require.NoError(t, tb.PutRaw([]byte("foo"), []byte("baz")))
+
+		v, err := tb.GetRaw([]byte("foo"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("baz"), v)
+
+		err = tb.Iterate(func(_ document.Document) error {
+			return errors.New("should not iterate")
+		})
+		require.NoError(t, err)
+
+		idx, err := tx.GetIndex("idxFoo")
+		require.NoError(t, err)
+		err = idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+			return errors.New("should not iterate")
+		})
+		require.NoError(t, err)
+
+		tables, err := tx.ListTables()
+		require.NoError(t, err)
+		require.Equal(t, []string{"test"}, tables)
+	})
+
+	t.Run("Should be deleted with the table", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		require.NoError(t, tb.PutRaw([]byte("foo"), []byte("bar")))
+
+		err = tx.DropTable("test")
+		require.NoError(t, err)
+		err = tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		tb, err = tx.GetTable("test")
+		require.NoError(t, err)
+
+		_, err = tb.GetRaw([]byte("foo"))
+		require.Equal(t, database.ErrDocumentNotFound, err)
+	})
+}
+
 func TestTableIndexes(t *testing.T) {
 	t.Run("Should succeed if table has no indexes", func(t *testing.T) {
 		tb, cleanup := newTestTable(t)
@@ -565,6 +1106,60 @@ func BenchmarkTableInsert(b *testing.B) {
 	}
 }
 
+// BenchmarkTableInsertBatch compares inserting 1000 documents in a table with two indexes
+// one by one and by batch.
+func BenchmarkTableInsertBatch(b *testing.B) {
+	docs := make([]document.Document, 1000)
+	for i := range docs {
+		docs[i] = document.NewFieldBuffer().
+			Add("a", document.NewInt64Value(int64(i%7))).
+			Add("b", document.NewInt64Value(int64(len(docs)-i)))
+	}
+
+	setup := func(b *testing.B) (*database.Table, func()) {
+		tx, cleanup := newTestDB(b)
+
+		err := tx.CreateTable("test", nil)
+		require.NoError(b, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_a", TableName: "test", Path: []string{"a"}})
+		require.NoError(b, err)
+		err = tx.CreateIndex(database.IndexConfig{IndexName: "idx_b", TableName: "test", Path: []string{"b"}})
+		require.NoError(b, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(b, err)
+
+		return tb, cleanup
+	}
+
+	b.Run("Insert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tb, cleanup := setup(b)
+			b.StartTimer()
+
+			for _, d := range docs {
+				tb.Insert(d)
+			}
+
+			b.StopTimer()
+			cleanup()
+		}
+	})
+
+	b.Run("InsertBatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tb, cleanup := setup(b)
+			b.StartTimer()
+
+			tb.InsertBatch(docs)
+
+			b.StopTimer()
+			cleanup()
+		}
+	})
+}
+
 // BenchmarkTableScan benchmarks the Scan method with 1, 10, 1000 and 10000 successive insertions.
 func BenchmarkTableScan(b *testing.B) {
 	for size := 1; size <= 10000; size *= 10 {
@@ -13,6 +13,10 @@ import (
 var (
 	tableConfigStoreName = "__genji.tables"
 	indexStoreName       = "__genji.indexes"
+	rawStorePrefix       = "__genji.raw."
+	compactStorePrefix   = "__genji.compact."
+	// marks the temporary store of a table being compacted as complete
+	compactDoneStorePrefix = "__genji.compacted."
 )
 
 // Transaction represents a database transaction. It provides methods for managing the
@@ -25,6 +29,7 @@ type Transaction struct {
 	writable   bool
 	tcfgStore  *tableConfigStore
 	indexStore *indexStore
+	cache      *txCache
 }
 
 // Rollback the transaction. Can be used safely after commit.
@@ -33,8 +38,21 @@ func (tx *Transaction) Rollback() error {
 }
 
 // Commit the transaction.
+// Documents modified by the transaction are removed from the table caches.
 func (tx *Transaction) Commit() error {
-	return tx.Tx.Commit()
+	if tx.cache == nil || len(tx.cache.keys) == 0 {
+		return tx.Tx.Commit()
+	}
+
+	tx.db.invalidateCaches(tx.cache)
+	err := tx.Tx.Commit()
+	tx.db.invalidateCaches(tx.cache)
+	return err
+}
+
+// DB returns the database the transaction was started from.
+func (tx *Transaction) DB() *Database {
+	return tx.db
 }
 
 // Writable indicates if the transaction is writable or not.
@@ -122,6 +140,16 @@ func (tx Transaction) DropTable(name string) error {
 		return err
 	}
 
+	if tx.cache != nil {
+		tx.cache.keys[name] = nil
+		tx.cache.purged[name] = true
+	}
+
+	err = tx.Tx.DropStore(rawStorePrefix + name)
+	if err != nil && err != engine.ErrStoreNotFound {
+		return err
+	}
+
 	return tx.Tx.DropStore(name)
 }
 
@@ -138,7 +166,8 @@ func (tx Transaction) ListTables() ([]string, error) {
 		if st == indexStoreName || st == tableConfigStoreName {
 			continue
 		}
-		if strings.HasPrefix(st, index.StorePrefix) {
+		if strings.HasPrefix(st, index.StorePrefix) || strings.HasPrefix(st, rawStorePrefix) ||
+			strings.HasPrefix(st, compactStorePrefix) || strings.HasPrefix(st, compactDoneStorePrefix) {
 			continue
 		}
 
@@ -232,9 +261,12 @@ func (tx Transaction) ReIndex(indexName string) error {
 
 	return tb.Iterate(func(d document.Document) error {
 		v, err := idx.Path.GetValue(d)
-		if err != nil {
+		if err != nil && err != document.ErrFieldNotFound {
 			return err
 		}
+		if err == document.ErrFieldNotFound {
+			v = document.NewNullValue()
+		}
 
 		return idx.Set(v, d.(document.Keyer).Key())
 	})
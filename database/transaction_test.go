@@ -19,6 +19,7 @@ func newTestDB(t testing.TB) (*database.Transaction, func()) {
 
 	return tx, func() {
 		tx.Rollback()
+		db.Close()
 	}
 }
 
@@ -3,6 +3,9 @@ package genji
 import (
 	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"os"
+	"time"
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
@@ -12,42 +15,120 @@ import (
 	"github.com/asdine/genji/sql/parser"
 	"github.com/asdine/genji/sql/query"
 	"github.com/dgraph-io/badger/v2"
+	bolt "github.com/etcd-io/bbolt"
 )
 
 // Open creates a Genji database at the given path.
 // If path is equal to ":memory:" it will open an in memory database,
 // otherwise it will create an on-disk database using the BoltDB engine.
 func Open(path string) (*DB, error) {
+	return OpenWithOptions(path, Options{})
+}
+
+// Options of the database.
+type Options struct {
+	// ReadOnly opens an existing database in read-only mode.
+	// Read-write transactions, and therefore any statement that modifies the database,
+	// fail with database.ErrReadOnly. No file is created, and the database must
+	// have been opened at least once in read-write mode before.
+	//
+	// With the BoltDB engine, the file is opened with a shared lock: any number of processes
+	// can open the same file in read-only mode concurrently, but opening it blocks
+	// as long as another process holds it in read-write mode, and vice versa.
+	// With the Badger engine, the engine must also be opened with the ReadOnly option,
+	// which allows multiple processes to open the same directory in read-only mode, as long as
+	// no process has it opened in read-write mode.
+	// In memory databases can't be opened in read-only mode.
+	ReadOnly bool
+
+	// OnSlowQuery, if not nil, is called for every query run with the Query, QueryDocument
+	// and Exec methods of DB and Tx that takes longer than SlowQueryThreshold.
+	// The duration of a query is measured from the moment it is parsed until its result
+	// is closed, so it includes the time spent by the caller iterating over the result.
+	// Queries that fail are not reported.
+	// Statistics are only collected if OnSlowQuery is set.
+	OnSlowQuery func(SlowQuery)
+	// SlowQueryThreshold is the duration above which a query is passed to OnSlowQuery.
+	SlowQueryThreshold time.Duration
+
+	// SortBufferSize is the maximum size, in bytes, of the documents kept in memory
+	// when sorting the results of a query that can't use an index. Beyond that size,
+	// sorted runs are written to temporary files created in SortTempDir.
+	// See database.Options for the default values.
+	SortBufferSize int
+	SortTempDir    string
+}
+
+// SlowQuery describes a query that took longer than Options.SlowQueryThreshold.
+type SlowQuery struct {
+	// Query is the query, as passed to Query or Exec.
+	Query string
+	// Elapsed is the duration of the query.
+	Elapsed time.Duration
+	// Stats holds the statistics of the query.
+	Stats query.Stats
+}
+
+// OpenWithOptions creates a Genji database at the given path, like Open, using the given options.
+func OpenWithOptions(path string, opts Options) (*DB, error) {
 	var ng engine.Engine
 	var err error
 
 	switch path {
 	case ":memory:":
+		if opts.ReadOnly {
+			return nil, errors.New("in memory databases can't be opened in read-only mode")
+		}
 		ng, err = badgerengine.NewEngine(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
 	default:
-		ng, err = boltengine.NewEngine(path, 0660, nil)
+		if opts.ReadOnly {
+			// Bolt creates the file even in read-only mode
+			if _, err = os.Stat(path); err != nil {
+				return nil, err
+			}
+		}
+		ng, err = boltengine.NewEngine(path, 0660, &bolt.Options{ReadOnly: opts.ReadOnly})
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return New(ng)
+	db, err := NewWithOptions(ng, opts)
+	if err != nil {
+		ng.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
 
 // DB represents a collection of tables stored in the underlying engine.
 type DB struct {
 	DB *database.Database
+
+	opts Options
 }
 
 // New initializes the DB using the given engine.
 func New(ng engine.Engine) (*DB, error) {
-	db, err := database.New(ng)
+	return NewWithOptions(ng, Options{})
+}
+
+// NewWithOptions initializes the DB using the given engine and options.
+// If opts.ReadOnly is true, the engine must have been opened in read-only mode.
+func NewWithOptions(ng engine.Engine, opts Options) (*DB, error) {
+	db, err := database.NewWithOptions(ng, database.Options{
+		ReadOnly:       opts.ReadOnly,
+		SortBufferSize: opts.SortBufferSize,
+		SortTempDir:    opts.SortTempDir,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &DB{
-		DB: db,
+		DB:   db,
+		opts: opts,
 	}, nil
 }
 
@@ -66,6 +147,7 @@ func (db *DB) Begin(writable bool) (*Tx, error) {
 
 	return &Tx{
 		Transaction: tx,
+		db:          db,
 	}, nil
 }
 
@@ -109,14 +191,41 @@ func (db *DB) Exec(q string, args ...interface{}) error {
 // Query the database and return the result.
 // The returned result must always be closed after usage.
 func (db *DB) Query(q string, args ...interface{}) (*query.Result, error) {
+	var start time.Time
+	if db.opts.OnSlowQuery != nil {
+		start = time.Now()
+	}
+
 	pq, err := parser.ParseQuery(q)
 	if err != nil {
 		return nil, err
 	}
+	db.watchQuery(&pq, q, start)
 
 	return pq.Run(db.DB, argsToNamedValues(args))
 }
 
+// watchQuery makes pq collect its statistics and report them to the slow query hook
+// once its result is closed, if the query took too long. It does nothing if no hook is set.
+func (db *DB) watchQuery(pq *query.Query, q string, start time.Time) {
+	if db == nil || db.opts.OnSlowQuery == nil {
+		return
+	}
+
+	var stats query.Stats
+	pq.Stats = &stats
+	pq.OnClose = func() {
+		elapsed := time.Since(start)
+		if elapsed > db.opts.SlowQueryThreshold {
+			db.opts.OnSlowQuery(SlowQuery{
+				Query:   q,
+				Elapsed: elapsed,
+				Stats:   stats,
+			})
+		}
+	}
+}
+
 // QueryDocument runs the query and returns the first document.
 // If the query returns no error, QueryDocument returns ErrDocumentNotFound.
 func (db *DB) QueryDocument(q string, args ...interface{}) (document.Document, error) {
@@ -175,17 +284,28 @@ func (db *DB) UpdateTable(tableName string, fn func(*Tx, *database.Table) error)
 // collection of tables and the transaction itself.
 // Tx is either read-only or read/write. Read-only can be used to read tables
 // and read/write can be used to read, create, delete and modify tables.
+// All the tables returned by GetTable share the transaction, so changes made
+// to multiple tables are committed or rolled back atomically.
 type Tx struct {
 	*database.Transaction
+
+	db *DB
 }
 
 // Query the database withing the transaction and returns the result.
-// Closing the returned result after usage is not mandatory.
+// Closing the returned result after usage is not mandatory, unless
+// a slow query hook is set.
 func (tx *Tx) Query(q string, args ...interface{}) (*query.Result, error) {
+	var start time.Time
+	if tx.db != nil && tx.db.opts.OnSlowQuery != nil {
+		start = time.Now()
+	}
+
 	pq, err := parser.ParseQuery(q)
 	if err != nil {
 		return nil, err
 	}
+	tx.db.watchQuery(&pq, q, start)
 
 	return pq.Exec(tx.Transaction, argsToNamedValues(args), false)
 }
@@ -210,6 +330,20 @@ func (tx *Tx) QueryDocument(q string, args ...interface{}) (document.Document, e
 	return r, nil
 }
 
+// Select returns all the documents of the table satisfying all the given conditions,
+// without requiring any struct or SQL query. Nil conditions are ignored, and if there
+// are none, all the documents of the table are returned.
+// Conditions are evaluated like a where clause, so indexes are used when possible.
+func (tx *Tx) Select(tableName string, conditions ...query.Expr) (*query.Result, error) {
+	stmt := query.SelectStmt{
+		TableName: tableName,
+		WhereExpr: query.AndIfPresent(conditions...),
+		Selectors: []query.ResultField{query.Wildcard{}},
+	}
+
+	return query.New(stmt).Exec(tx.Transaction, nil, false)
+}
+
 // Exec a query against the database within tx and without returning the result.
 func (tx *Tx) Exec(q string, args ...interface{}) error {
 	res, err := tx.Query(q, args...)
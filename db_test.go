@@ -1,13 +1,19 @@
 package genji_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
 	"github.com/stretchr/testify/require"
 )
 
@@ -67,6 +73,70 @@ func ExampleTx() {
 	// 10 foo 15
 }
 
+func ExampleDB_Update() {
+	db, err := genji.Open(":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE author; CREATE TABLE book")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// documents inserted in both tables are committed together
+	err = db.Update(func(tx *genji.Tx) error {
+		authors, err := tx.GetTable("author")
+		if err != nil {
+			return err
+		}
+
+		books, err := tx.GetTable("book")
+		if err != nil {
+			return err
+		}
+
+		_, err = authors.Insert(document.NewFieldBuffer().
+			Add("id", document.NewIntValue(1)).
+			Add("name", document.NewTextValue("foo")))
+		if err != nil {
+			return err
+		}
+
+		_, err = books.Insert(document.NewFieldBuffer().
+			Add("author_id", document.NewIntValue(1)).
+			Add("title", document.NewTextValue("bar")))
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// if any operation fails, changes made to both tables are rolled back
+	err = db.Update(func(tx *genji.Tx) error {
+		err := tx.Exec("INSERT INTO author (id, name) VALUES (2, 'baz')")
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec("INSERT INTO unknown (author_id, title) VALUES (2, 'qux')")
+	})
+	fmt.Println(err)
+
+	err = db.ViewTable("author", func(_ *genji.Tx, tb *database.Table) error {
+		n, err := tb.Count()
+		fmt.Println(n)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output: table not found
+	// 1
+}
+
 func TestQueryDocument(t *testing.T) {
 	db, err := genji.Open(":memory:")
 	require.NoError(t, err)
@@ -116,3 +186,179 @@ func TestQueryDocument(t *testing.T) {
 		require.Nil(t, r)
 	})
 }
+
+func TestTxSelect(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+			CREATE TABLE test;
+			CREATE INDEX idx_a ON test (a);
+			INSERT INTO test (a, b) VALUES (1, 'foo'), (2, 'bar'), (3, 'foo')
+		`)
+	require.NoError(t, err)
+
+	err = db.View(func(tx *genji.Tx) error {
+		res, err := tx.Select("test",
+			query.Gte(query.FieldSelector([]string{"a"}), query.IntValue(2)),
+			nil,
+			query.Eq(query.FieldSelector([]string{"b"}), query.TextValue("foo")),
+		)
+		require.NoError(t, err)
+		defer res.Close()
+
+		var buf bytes.Buffer
+		err = document.IteratorToJSONArray(&buf, res)
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"a": 3, "b": "foo"}]`, buf.String())
+
+		res, err = tx.Select("test")
+		require.NoError(t, err)
+		defer res.Close()
+
+		n, err := res.Count()
+		require.NoError(t, err)
+		require.Equal(t, 3, n)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.db")
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := genji.OpenWithOptions(path, genji.Options{ReadOnly: true})
+		require.Error(t, err)
+
+		_, err = os.Stat(path)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	db, err := genji.Open(path)
+	require.NoError(t, err)
+	err = db.Exec(`
+		CREATE TABLE test;
+		INSERT INTO test (a) VALUES (1), (2);
+	`)
+	require.NoError(t, err)
+	err = db.Close()
+	require.NoError(t, err)
+
+	t.Run("Concurrent readers", func(t *testing.T) {
+		db1, err := genji.OpenWithOptions(path, genji.Options{ReadOnly: true})
+		require.NoError(t, err)
+		defer db1.Close()
+
+		db2, err := genji.OpenWithOptions(path, genji.Options{ReadOnly: true})
+		require.NoError(t, err)
+		defer db2.Close()
+
+		for _, db := range []*genji.DB{db1, db2} {
+			d, err := db.QueryDocument("SELECT COUNT(*) AS c FROM test")
+			require.NoError(t, err)
+			v, err := d.GetByField("c")
+			require.NoError(t, err)
+			require.Equal(t, document.NewInt64Value(2), v)
+		}
+	})
+
+	t.Run("Writes", func(t *testing.T) {
+		db, err := genji.OpenWithOptions(path, genji.Options{ReadOnly: true})
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec("INSERT INTO test (a) VALUES (3)")
+		require.Equal(t, database.ErrReadOnly, err)
+
+		err = db.Update(func(tx *genji.Tx) error {
+			return nil
+		})
+		require.Equal(t, database.ErrReadOnly, err)
+
+		_, err = db.Begin(true)
+		require.Equal(t, database.ErrReadOnly, err)
+	})
+
+	t.Run("In memory", func(t *testing.T) {
+		_, err := genji.OpenWithOptions(":memory:", genji.Options{ReadOnly: true})
+		require.Error(t, err)
+	})
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	var slow []genji.SlowQuery
+	db, err := genji.OpenWithOptions(":memory:", genji.Options{
+		OnSlowQuery: func(q genji.SlowQuery) {
+			slow = append(slow, q)
+		},
+		SlowQueryThreshold: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test; INSERT INTO test (a) VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	require.Empty(t, slow)
+
+	res, err := db.Query("SELECT * FROM test WHERE a > 1")
+	require.NoError(t, err)
+	err = res.Iterate(func(d document.Document) error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Empty(t, slow)
+	err = res.Close()
+	require.NoError(t, err)
+
+	require.Len(t, slow, 1)
+	require.Equal(t, "SELECT * FROM test WHERE a > 1", slow[0].Query)
+	require.True(t, slow[0].Elapsed >= 60*time.Millisecond)
+	require.Equal(t, 3, slow[0].Stats.TableReads)
+	require.Equal(t, 2, slow[0].Stats.DocumentsMatched)
+
+	err = db.View(func(tx *genji.Tx) error {
+		res, err := tx.Query("SELECT * FROM test")
+		if err != nil {
+			return err
+		}
+		time.Sleep(50 * time.Millisecond)
+		return res.Close()
+	})
+	require.NoError(t, err)
+	require.Len(t, slow, 2)
+	require.Equal(t, "SELECT * FROM test", slow[1].Query)
+}
+
+func TestStructSliceFields(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	type post struct {
+		ID     int
+		Tags   []string
+		Scores []int
+	}
+
+	err = db.Exec("CREATE TABLE post")
+	require.NoError(t, err)
+
+	p := post{ID: 1, Tags: []string{"a", "b"}, Scores: []int{10, -20, 30}}
+	err = db.Exec("INSERT INTO post VALUES ?", &p)
+	require.NoError(t, err)
+
+	d, err := db.QueryDocument("SELECT * FROM post WHERE tags = ['a', 'b']")
+	require.NoError(t, err)
+
+	var res post
+	err = document.StructScan(d, &res)
+	require.NoError(t, err)
+	require.Equal(t, p, res)
+}
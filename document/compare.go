@@ -4,8 +4,29 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrIncompatibleTypes is returned when comparing two values whose types can't be compared,
+// like a text and an integer. Comparisons involving a null value never return this error.
+type ErrIncompatibleTypes struct {
+	Left, Right ValueType
+}
+
+func (e *ErrIncompatibleTypes) Error() string {
+	return fmt.Sprintf("cannot compare %s with %s", e.Left, e.Right)
+}
+
+func isIncompatible(err error) bool {
+	_, ok := err.(*ErrIncompatibleTypes)
+	return ok
+}
+
 type operator uint8
 
 const (
@@ -38,6 +59,16 @@ func (v Value) IsEqual(other Value) (bool, error) {
 	return compare(operatorEq, v, other)
 }
 
+// IsEqualFold is like IsEqual but texts and blobs are compared without regard to case,
+// using Unicode case folding. Values of other types are compared with IsEqual.
+func (v Value) IsEqualFold(other Value) (bool, error) {
+	if (v.Type == TextValue || v.Type == BlobValue) && (other.Type == TextValue || other.Type == BlobValue) {
+		return strings.EqualFold(string(v.V.([]byte)), string(other.V.([]byte))), nil
+	}
+
+	return v.IsEqual(other)
+}
+
 // IsNotEqual returns true if v is not equal to the given value.
 func (v Value) IsNotEqual(other Value) (bool, error) {
 	ok, err := v.IsEqual(other)
@@ -68,6 +99,233 @@ func (v Value) IsLesserThanOrEqual(other Value) (bool, error) {
 	return compare(operatorLte, v, other)
 }
 
+// IsBetween returns true if v is greater than or equal to lower and lesser than or equal to upper.
+// The values are compared with the same rules as the other comparison methods.
+func (v Value) IsBetween(lower, upper Value) (bool, error) {
+	ok, err := compare(operatorGte, v, lower)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return compare(operatorLte, v, upper)
+}
+
+// IsIn returns true if v is equal to one of the given values, using the same rules as IsEqual.
+// It stops at the first match. As with Contains, values whose type can't be compared
+// with v are considered different, any other error is returned.
+func (v Value) IsIn(others []Value) (bool, error) {
+	for _, other := range others {
+		ok, err := v.IsEqual(other)
+		if err != nil && !isIncompatible(err) {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Contains returns true if v is an array and one of its elements is equal to the given value.
+// Elements are compared using IsEqual, which means that elements of a different type
+// than the given value never match, except for numbers which are compared by value.
+// It returns an error if v is not an array.
+func (v Value) Contains(element Value) (bool, error) {
+	if v.Type != ArrayValue {
+		return false, fmt.Errorf("can't look for a value in a %s", v.Type)
+	}
+
+	var found bool
+	err := v.V.(Array).Iterate(func(i int, ev Value) error {
+		ok, err := ev.IsEqual(element)
+		if err != nil && !isIncompatible(err) {
+			return err
+		}
+
+		if ok {
+			found = true
+			return errStop
+		}
+
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+
+	return found, err
+}
+
+// CompareCoerce compares v with other and returns -1 if v is lesser than other,
+// 0 if they are equal and 1 if v is greater than other.
+// Unlike the comparison operators, which consider a text and a number as different values,
+// a text compared with a number is first parsed to the type of the number.
+// Likewise, a text compared with a UUID or a time is parsed as a UUID or a time,
+// and an integer compared with a boolean is converted to a boolean if it is 0 or 1.
+// It returns an error if the text is not a valid number, UUID or time, if the integer
+// is not 0 or 1, or if the values can't be ordered.
+func (v Value) CompareCoerce(other Value) (int, error) {
+	var err error
+
+	switch {
+	case v.Type == TextValue && other.Type.IsNumber():
+		v, err = parseTextToNumber(v, other.Type)
+	case other.Type == TextValue && v.Type.IsNumber():
+		other, err = parseTextToNumber(other, v.Type)
+	case v.Type == TextValue && other.Type == UUIDValue:
+		v, err = v.ConvertTo(UUIDValue)
+	case other.Type == TextValue && v.Type == UUIDValue:
+		other, err = other.ConvertTo(UUIDValue)
+	case v.Type == TextValue && other.Type == TimeValue:
+		v, err = v.ConvertTo(TimeValue)
+	case other.Type == TextValue && v.Type == TimeValue:
+		other, err = other.ConvertTo(TimeValue)
+	case v.Type.IsInteger() && v.Type != DurationValue && other.Type == BoolValue:
+		v, err = integerToBool(v)
+	case other.Type.IsInteger() && other.Type != DurationValue && v.Type == BoolValue:
+		other, err = integerToBool(other)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range []struct {
+		op  operator
+		res int
+	}{{operatorEq, 0}, {operatorLt, -1}, {operatorGt, 1}} {
+		ok, err := compare(c.op, v, other)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return c.res, nil
+		}
+	}
+
+	return 0, &ErrIncompatibleTypes{v.Type, other.Type}
+}
+
+// integerToBool converts the integer v to a boolean if it is equal to 0 or 1.
+func integerToBool(v Value) (Value, error) {
+	x, err := v.ConvertToInt64()
+	if err != nil {
+		return Value{}, err
+	}
+
+	if x != 0 && x != 1 {
+		return Value{}, fmt.Errorf("cannot convert %d to bool", x)
+	}
+
+	return NewBoolValue(x == 1), nil
+}
+
+// parseTextToNumber parses the text value v to a number of type t.
+// Integers that can't be parsed as such are parsed as floats, so that
+// they can still be compared with decimal numbers.
+func parseTextToNumber(v Value, t ValueType) (Value, error) {
+	s := strings.TrimSpace(string(v.V.([]byte)))
+
+	if t == DurationValue {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return Value{}, fmt.Errorf("cannot parse %q as %s: %v", s, t, err)
+		}
+		return NewDurationValue(d), nil
+	}
+
+	if t.IsInteger() {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return NewInt64Value(i), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("cannot parse %q as %s: %v", s, t, err)
+	}
+
+	return NewFloat64Value(f), nil
+}
+
+// StrictEqual returns true if v and other have the same type and the same content.
+// Unlike IsEqual, no conversion is performed: an int8 is never strictly equal to an int64
+// or a float64, and a text is never strictly equal to a blob, even if they represent
+// the same value. Floats are compared bit by bit, so a NaN is strictly equal to itself.
+// Fields of documents must appear in the same order for two documents to be strictly equal.
+// It is useful for cache keys, deduplication or change detection, when the type of the
+// values matters. Use IsEqual to compare values regardless of their types.
+func (v Value) StrictEqual(other Value) bool {
+	if v.Type != other.Type {
+		return false
+	}
+
+	switch v.Type {
+	case NullValue:
+		return true
+	case TextValue, BlobValue:
+		return bytes.Equal(v.V.([]byte), other.V.([]byte))
+	case IPValue:
+		return bytes.Equal(v.V.(net.IP), other.V.(net.IP))
+	case Float64Value:
+		return math.Float64bits(v.V.(float64)) == math.Float64bits(other.V.(float64))
+	case DocumentValue:
+		return strictEqualDocuments(v.V.(Document), other.V.(Document))
+	case ArrayValue:
+		return strictEqualArrays(v.V.(Array), other.V.(Array))
+	}
+
+	return v.V == other.V
+}
+
+func strictEqualDocuments(l, r Document) bool {
+	var lfields []string
+	var lvalues []Value
+	err := l.Iterate(func(f string, v Value) error {
+		lfields = append(lfields, f)
+		lvalues = append(lvalues, v)
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	var i int
+	err = r.Iterate(func(f string, v Value) error {
+		if i >= len(lfields) || lfields[i] != f || !lvalues[i].StrictEqual(v) {
+			return errStop
+		}
+		i++
+		return nil
+	})
+
+	return err == nil && i == len(lfields)
+}
+
+func strictEqualArrays(l, r Array) bool {
+	var lvalues []Value
+	err := l.Iterate(func(_ int, v Value) error {
+		lvalues = append(lvalues, v)
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	var i int
+	err = r.Iterate(func(_ int, v Value) error {
+		if i >= len(lvalues) || !lvalues[i].StrictEqual(v) {
+			return errStop
+		}
+		i++
+		return nil
+	})
+
+	return err == nil && i == len(lvalues)
+}
+
 func compare(op operator, l, r Value) (bool, error) {
 	switch {
 	// deal with nil
@@ -84,9 +342,21 @@ func compare(op operator, l, r Value) (bool, error) {
 	case l.Type == ArrayValue && r.Type == ArrayValue:
 		return compareArrays(op, l, r)
 
-	// compare boolean and another value
+	// ip addresses can only be compared together
+	case l.Type == IPValue || r.Type == IPValue:
+		return compareIPs(op, l, r)
+
+	// uuids can only be compared together
+	case l.Type == UUIDValue || r.Type == UUIDValue:
+		return compareUUIDs(op, l, r)
+
+	// times can only be compared together
+	case l.Type == TimeValue || r.Type == TimeValue:
+		return compareTimes(op, l, r)
+
+	// booleans can only be compared together
 	case l.Type == BoolValue || r.Type == BoolValue:
-		return compareWithBool(op, l, r)
+		return compareBools(op, l, r)
 
 	// compare strings and bytes together
 	case l.Type == TextValue && r.Type == TextValue:
@@ -107,7 +377,7 @@ func compare(op operator, l, r Value) (bool, error) {
 		return compareNumbers(op, l, r)
 	}
 
-	return false, nil
+	return false, &ErrIncompatibleTypes{l.Type, r.Type}
 }
 
 func compareWithNull(op operator, l, r Value) (bool, error) {
@@ -121,39 +391,26 @@ func compareWithNull(op operator, l, r Value) (bool, error) {
 	return false, fmt.Errorf("unknown operator %v", op)
 }
 
-// when comparing booleans with numbers, true equals 1 and false 0
-// when comparing booleans with other types, the boolean is always smaller.
-func compareWithBool(op operator, l, r Value) (bool, error) {
-	// if comparing a boolean with something other than a number or another bool, always return false.
-	if (!l.Type.IsNumber() && l.Type != BoolValue) || (!r.Type.IsNumber() && r.Type != BoolValue) {
-		return false, nil
+// compareBools compares two booleans, false being lesser than true.
+// Comparing a boolean with a value of another type returns an *ErrIncompatibleTypes error.
+func compareBools(op operator, l, r Value) (bool, error) {
+	if l.Type != r.Type {
+		return false, &ErrIncompatibleTypes{l.Type, r.Type}
 	}
 
-	var a, b bool
-
-	if l.Type == BoolValue {
-		a = l.V.(bool)
-	} else {
-		a = l.IsTruthy()
-	}
-
-	if r.Type == BoolValue {
-		b = r.V.(bool)
-	} else {
-		b = r.IsTruthy()
-	}
+	a, b := l.V.(bool), r.V.(bool)
 
 	switch op {
 	case operatorEq:
 		return a == b, nil
 	case operatorGt:
-		return a == true && b == false, nil
+		return a && !b, nil
 	case operatorGte:
-		return a == b || a == true, nil
+		return a || !b, nil
 	case operatorLt:
-		return a == false && b == true, nil
+		return !a && b, nil
 	case operatorLte:
-		return a == b || a == false, nil
+		return !a || b, nil
 	}
 
 	return false, fmt.Errorf("unknown operator %v", op)
@@ -178,6 +435,84 @@ func compareBytes(op operator, l, r Value) (bool, error) {
 	return ok, nil
 }
 
+func compareIPs(op operator, l, r Value) (bool, error) {
+	if l.Type != r.Type {
+		return false, &ErrIncompatibleTypes{l.Type, r.Type}
+	}
+
+	// ip values are always stored in their 16-byte form,
+	// which sorts IPv4 and IPv6 addresses consistently
+	c := bytes.Compare(l.V.(net.IP), r.V.(net.IP))
+
+	var ok bool
+
+	switch op {
+	case operatorEq:
+		ok = c == 0
+	case operatorGt:
+		ok = c > 0
+	case operatorGte:
+		ok = c >= 0
+	case operatorLt:
+		ok = c < 0
+	case operatorLte:
+		ok = c <= 0
+	}
+
+	return ok, nil
+}
+
+func compareUUIDs(op operator, l, r Value) (bool, error) {
+	if l.Type != r.Type {
+		return false, &ErrIncompatibleTypes{l.Type, r.Type}
+	}
+
+	lu, ru := l.V.([16]byte), r.V.([16]byte)
+	c := bytes.Compare(lu[:], ru[:])
+
+	var ok bool
+
+	switch op {
+	case operatorEq:
+		ok = c == 0
+	case operatorGt:
+		ok = c > 0
+	case operatorGte:
+		ok = c >= 0
+	case operatorLt:
+		ok = c < 0
+	case operatorLte:
+		ok = c <= 0
+	}
+
+	return ok, nil
+}
+
+func compareTimes(op operator, l, r Value) (bool, error) {
+	if l.Type != r.Type {
+		return false, &ErrIncompatibleTypes{l.Type, r.Type}
+	}
+
+	lt, rt := l.V.(time.Time), r.V.(time.Time)
+
+	var ok bool
+
+	switch op {
+	case operatorEq:
+		ok = lt.Equal(rt)
+	case operatorGt:
+		ok = lt.After(rt)
+	case operatorGte:
+		ok = !lt.Before(rt)
+	case operatorLt:
+		ok = lt.Before(rt)
+	case operatorLte:
+		ok = !lt.After(rt)
+	}
+
+	return ok, nil
+}
+
 func compareIntegers(op operator, l, r Value) (bool, error) {
 	// integer OP integer
 	ai, err := l.ConvertToInt64()
@@ -208,42 +543,110 @@ func compareIntegers(op operator, l, r Value) (bool, error) {
 	return ok, nil
 }
 
+// compareNumbers compares two numbers, at least one of which is a float.
+// Unlike IEEE 754 comparisons, NaN is equal to itself and greater than any other number,
+// including +Inf, so that all numbers are totally ordered. This is the order
+// in which numbers are sorted in indexes.
+// When an integer is compared with a float, the comparison is exact: the integer
+// is not converted to a float, which would lose precision beyond 2^53.
 func compareNumbers(op operator, l, r Value) (bool, error) {
-	af, err := l.ConvertToFloat64()
-	if err != nil {
-		return false, err
-	}
+	var c int
 
-	bf, err := r.ConvertToFloat64()
-	if err != nil {
-		return false, err
+	switch {
+	case l.Type.IsInteger():
+		ai, err := l.ConvertToInt64()
+		if err != nil {
+			return false, err
+		}
+		c = compareIntegerWithFloat(ai, r.V.(float64))
+	case r.Type.IsInteger():
+		bi, err := r.ConvertToInt64()
+		if err != nil {
+			return false, err
+		}
+		c = -compareIntegerWithFloat(bi, l.V.(float64))
+	default:
+		c = compareFloats(l.V.(float64), r.V.(float64))
 	}
 
 	var ok bool
 
 	switch op {
 	case operatorEq:
-		ok = af == bf
+		ok = c == 0
 	case operatorGt:
-		ok = af > bf
+		ok = c > 0
 	case operatorGte:
-		ok = af >= bf
+		ok = c >= 0
 	case operatorLt:
-		ok = af < bf
+		ok = c < 0
 	case operatorLte:
-		ok = af <= bf
+		ok = c <= 0
 	}
 
 	return ok, nil
 }
 
-var errStop = errors.New("stop")
+// compareFloats returns -1, 0 or 1 depending on whether a is lesser than, equal to
+// or greater than b, NaN being greater than any other number.
+func compareFloats(a, b float64) int {
+	switch an, bn := math.IsNaN(a), math.IsNaN(b); {
+	case an && bn:
+		return 0
+	case an:
+		return 1
+	case bn:
+		return -1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
 
-func compareDocuments(op operator, l, r Value) (bool, error) {
-	if op != operatorEq {
-		return false, fmt.Errorf("%q operator not supported for document comparison", op)
+	return 0
+}
+
+// compareIntegerWithFloat returns -1, 0 or 1 depending on whether i is lesser than,
+// equal to or greater than f, following the same ordering as compareFloats.
+// If f is a whole number within the range of int64 both are compared as integers,
+// otherwise the integer part of f is compared first and its fractional part breaks ties.
+func compareIntegerWithFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return -1
+	// float64(math.MaxInt64) is 2^63, which doesn't fit in an int64
+	case f >= math.MaxInt64:
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+
+	t := math.Trunc(f)
+	fi := int64(t)
+
+	switch {
+	case i < fi:
+		return -1
+	case i > fi:
+		return 1
+	case f > t:
+		return -1
+	case f < t:
+		return 1
 	}
 
+	return 0
+}
+
+var errStop = errors.New("stop")
+
+// compareDocuments compares two documents by comparing their fields sorted by name, one by one.
+// Fields are compared by name first, then by value, which means that if both documents have
+// the same fields up to a certain field, the document with the field whose name sorts first
+// is the lesser one. If all the fields are equal, the document with less fields is the lesser one.
+// As with arrays, if two values of the same field can't be ordered, for example if they have
+// different types, the documents are neither equal, lesser nor greater than each other.
+func compareDocuments(op operator, l, r Value) (bool, error) {
 	ld, err := l.ConvertToDocument()
 	if err != nil {
 		return false, err
@@ -254,56 +657,69 @@ func compareDocuments(op operator, l, r Value) (bool, error) {
 		return false, err
 	}
 
-	var lsize, rsize int
-	err = ld.Iterate(func(field string, lv Value) error {
-		lsize++
-		return nil
-	})
+	lfields, err := sortedFields(ld)
 	if err != nil {
 		return false, err
 	}
-	err = rd.Iterate(func(field string, lv Value) error {
-		rsize++
-		return nil
-	})
+
+	rfields, err := sortedFields(rd)
 	if err != nil {
 		return false, err
 	}
 
-	if lsize != rsize {
-		return false, nil
-	}
+	for i := 0; i < len(lfields) && i < len(rfields); i++ {
+		lf, rf := lfields[i], rfields[i]
 
-	// if both empty documents
-	if lsize == 0 {
-		return true, nil
-	}
+		if lf.name != rf.name {
+			if op == operatorEq {
+				return false, nil
+			}
 
-	var ok bool
+			return compareBytes(op, NewTextValue(lf.name), NewTextValue(rf.name))
+		}
 
-	err = ld.Iterate(func(field string, lv Value) error {
-		rv, err := rd.GetByField(field)
+		isEq, err := compare(operatorEq, lf.value, rf.value)
 		if err != nil {
-			return err
+			// values of incompatible types are neither equal nor ordered
+			if isIncompatible(err) {
+				return false, nil
+			}
+			return false, err
 		}
 
-		ok, err = compare(op, lv, rv)
-		if err != nil {
-			return err
+		if !isEq && op != operatorEq {
+			return compare(op, lf.value, rf.value)
 		}
 
-		if !ok {
-			return errStop
+		if !isEq {
+			return false, nil
 		}
+	}
+
+	return compareLengths(op, len(lfields), len(rfields)), nil
+}
+
+type field struct {
+	name  string
+	value Value
+}
 
+// sortedFields returns the fields of the document sorted by name.
+func sortedFields(d Document) ([]field, error) {
+	var fields []field
+	err := d.Iterate(func(f string, v Value) error {
+		fields = append(fields, field{f, v})
 		return nil
 	})
-
-	if err != nil && err != errStop {
-		return false, err
+	if err != nil {
+		return nil, err
 	}
 
-	return ok, nil
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+
+	return fields, nil
 }
 
 func compareArrays(op operator, l, r Value) (bool, error) {
@@ -336,6 +752,10 @@ func compareArrays(op operator, l, r Value) (bool, error) {
 
 		isEq, err := compare(operatorEq, lv, rv)
 		if err != nil {
+			// values of incompatible types are neither equal nor ordered
+			if isIncompatible(err) {
+				return false, nil
+			}
 			return false, err
 		}
 
@@ -348,27 +768,32 @@ func compareArrays(op operator, l, r Value) (bool, error) {
 		}
 	}
 
+	return compareLengths(op, i, j), nil
+}
+
+// compareLengths compares the lengths of two arrays or documents whose elements are all equal.
+func compareLengths(op operator, l, r int) bool {
 	switch {
-	case i > j:
+	case l > r:
 		switch op {
 		case operatorEq, operatorLt, operatorLte:
-			return false, nil
+			return false
 		default:
-			return true, nil
+			return true
 		}
-	case i < j:
+	case l < r:
 		switch op {
 		case operatorEq, operatorGt, operatorGte:
-			return false, nil
+			return false
 		default:
-			return true, nil
+			return true
 		}
 	default:
 		switch op {
 		case operatorEq, operatorGte, operatorLte:
-			return true, nil
+			return true
 		default:
-			return false, nil
+			return false
 		}
 	}
 }
@@ -3,6 +3,8 @@ package document_test
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"testing"
 	"time"
 
@@ -237,19 +239,39 @@ func TestComparisonTextWithNull(t *testing.T) {
 
 func TestComparisonDocuments(t *testing.T) {
 	tests := []struct {
-		op string
-		a  string
-		b  string
+		op       string
+		a        string
+		b        string
+		expected bool
 	}{
-		{"=", `{}`, `{}`},
-		{"=", `{"a": 1}`, `{"a": 1}`},
-		{
-			"=",
-			`{"a": 1, "b": 2}`,
-			`{"b": 2, "a": 1}`,
-		},
-		{">", `{"a": 2}`, `{"a": 1}`},
-		{"<", `{"a": 1}`, `{"a": 2}`},
+		{"=", `{}`, `{}`, true},
+		{"=", `{"a": 1}`, `{"a": 1}`, true},
+		{"=", `{"a": 1, "b": 2}`, `{"b": 2, "a": 1}`, true},
+		{"=", `{"a": 1.0}`, `{"a": 1}`, true},
+		{"=", `{"a": 1}`, `{"a": 1, "b": 2}`, false},
+		{"=", `{"a": 1}`, `{"b": 1}`, false},
+		{"!=", `{"a": 1}`, `{"a": 2}`, true},
+		{"!=", `{"a": 1, "b": 2}`, `{"b": 2, "a": 1}`, false},
+		{">", `{"a": 2}`, `{"a": 1}`, true},
+		{">", `{"a": 1}`, `{"a": 1}`, false},
+		{">", `{"a": 1, "b": 2}`, `{"b": 1, "a": 1}`, true},
+		{">", `{"a": 2}`, `{"a": 1, "b": 1000}`, true},
+		{">", `{"a": 1, "b": 1}`, `{"a": 1}`, true},
+		{">", `{"a": 1}`, `{}`, true},
+		// fields are compared by name first
+		{">", `{"b": 1}`, `{"a": 2}`, true},
+		{">", `{"a": 1, "c": 0}`, `{"a": 1, "b": 1000}`, true},
+		{">=", `{"a": 1}`, `{"a": 1}`, true},
+		{">=", `{"a": 1}`, `{"a": 1, "b": 1}`, false},
+		{"<", `{"a": 1}`, `{"a": 2}`, true},
+		{"<", `{"a": 1}`, `{"a": 1, "b": 1}`, true},
+		{"<", `{}`, `{"a": 1}`, true},
+		{"<", `{"a": {"b": 1}}`, `{"a": {"b": 2}}`, true},
+		{"<=", `{"a": 1}`, `{"a": 1}`, true},
+		{"<=", `{"a": 2}`, `{"a": 1, "b": 1}`, false},
+		// values of different types are not ordered
+		{"<", `{"a": 1}`, `{"a": "foo"}`, false},
+		{">", `{"a": 1}`, `{"a": "foo"}`, false},
 	}
 
 	for _, test := range tests {
@@ -264,21 +286,19 @@ func TestComparisonDocuments(t *testing.T) {
 			switch test.op {
 			case "=":
 				ok, err = document.NewDocumentValue(d1).IsEqual(document.NewDocumentValue(d2))
-				require.NoError(t, err)
-				require.True(t, ok)
 			case ">":
 				ok, err = document.NewDocumentValue(d1).IsGreaterThan(document.NewDocumentValue(d2))
-				require.Error(t, err)
 			case ">=":
 				ok, err = document.NewDocumentValue(d1).IsGreaterThanOrEqual(document.NewDocumentValue(d2))
-				require.Error(t, err)
 			case "<":
 				ok, err = document.NewDocumentValue(d1).IsLesserThan(document.NewDocumentValue(d2))
-				require.Error(t, err)
 			case "<=":
 				ok, err = document.NewDocumentValue(d1).IsLesserThanOrEqual(document.NewDocumentValue(d2))
-				require.Error(t, err)
+			case "!=":
+				ok, err = document.NewDocumentValue(d1).IsNotEqual(document.NewDocumentValue(d2))
 			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
 		})
 	}
 }
@@ -366,6 +386,111 @@ func TestComparisonArrays(t *testing.T) {
 	}
 }
 
+func TestComparisonNaN(t *testing.T) {
+	nan := document.NewFloat64Value(math.NaN())
+	negNaN := document.NewFloat64Value(math.Copysign(math.NaN(), -1))
+
+	tests := []struct {
+		name string
+		a, b document.Value
+		// expected result of a compared with b: -1, 0 or 1
+		expected int
+	}{
+		{"NaN and NaN", nan, nan, 0},
+		{"NaN and negative NaN", nan, negNaN, 0},
+		{"NaN and +Inf", nan, document.NewFloat64Value(math.Inf(1)), 1},
+		{"NaN and float", nan, document.NewFloat64Value(-1.5), 1},
+		{"NaN and integer", nan, document.NewInt64Value(math.MaxInt64), 1},
+		{"float and NaN", document.NewFloat64Value(math.MaxFloat64), nan, -1},
+		{"-Inf and NaN", document.NewFloat64Value(math.Inf(-1)), negNaN, -1},
+		{"integer and NaN", document.NewInt8Value(0), nan, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.a.IsEqual(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected == 0, ok)
+
+			ok, err = test.a.IsNotEqual(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected != 0, ok)
+
+			ok, err = test.a.IsGreaterThan(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected > 0, ok)
+
+			ok, err = test.a.IsGreaterThanOrEqual(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected >= 0, ok)
+
+			ok, err = test.a.IsLesserThan(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected < 0, ok)
+
+			ok, err = test.a.IsLesserThanOrEqual(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected <= 0, ok)
+		})
+	}
+}
+
+func TestComparisonIntegersWithFloats(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b document.Value
+		// expected result of a compared with b: -1, 0 or 1
+		expected int
+	}{
+		{"small", document.NewInt8Value(10), document.NewFloat64Value(10), 0},
+		{"fraction", document.NewInt8Value(10), document.NewFloat64Value(10.5), -1},
+		{"negative fraction", document.NewInt8Value(-10), document.NewFloat64Value(-10.5), 1},
+		{"2^53", document.NewInt64Value(1 << 53), document.NewFloat64Value(1 << 53), 0},
+		{"2^53+1", document.NewInt64Value(1<<53 + 1), document.NewFloat64Value(1 << 53), 1},
+		{"2^53-1", document.NewInt64Value(1<<53 - 1), document.NewFloat64Value(1 << 53), -1},
+		{"-2^53-1", document.NewInt64Value(-1<<53 - 1), document.NewFloat64Value(-1 << 53), -1},
+		{"2^62+1", document.NewInt64Value(1<<62 + 1), document.NewFloat64Value(1 << 62), 1},
+		{"MaxInt64", document.NewInt64Value(math.MaxInt64), document.NewFloat64Value(math.MaxInt64), -1},
+		{"MaxInt64 and 2^63-1024", document.NewInt64Value(math.MaxInt64), document.NewFloat64Value(1<<63 - 1024), 1},
+		{"MinInt64", document.NewInt64Value(math.MinInt64), document.NewFloat64Value(math.MinInt64), 0},
+		{"MinInt64+1", document.NewInt64Value(math.MinInt64 + 1), document.NewFloat64Value(math.MinInt64), 1},
+		{"MinInt64 and smaller float", document.NewInt64Value(math.MinInt64), document.NewFloat64Value(-1e19), 1},
+		{"+Inf", document.NewInt64Value(math.MaxInt64), document.NewFloat64Value(math.Inf(1)), -1},
+		{"-Inf", document.NewInt64Value(math.MinInt64), document.NewFloat64Value(math.Inf(-1)), 1},
+		{"duration", document.NewDurationValue(1<<53 + 1), document.NewFloat64Value(1 << 53), 1},
+	}
+
+	for _, test := range tests {
+		// check both operand orders
+		for _, tt := range []struct {
+			a, b     document.Value
+			expected int
+		}{{test.a, test.b, test.expected}, {test.b, test.a, -test.expected}} {
+			t.Run(fmt.Sprintf("%s/%s/%s", test.name, tt.a.Type, tt.b.Type), func(t *testing.T) {
+				ok, err := tt.a.IsEqual(tt.b)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected == 0, ok)
+
+				ok, err = tt.a.IsGreaterThan(tt.b)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected > 0, ok)
+
+				ok, err = tt.a.IsGreaterThanOrEqual(tt.b)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected >= 0, ok)
+
+				ok, err = tt.a.IsLesserThan(tt.b)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected < 0, ok)
+
+				ok, err = tt.a.IsLesserThanOrEqual(tt.b)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected <= 0, ok)
+			})
+		}
+	}
+}
+
 func TestComparisonDifferentTypes(t *testing.T) {
 	tests := []struct {
 		op string
@@ -404,7 +529,7 @@ func TestComparisonDifferentTypes(t *testing.T) {
 					case "<=":
 						ok, err = numericFuncs[i].fn(test.a).IsLesserThanOrEqual(textFuncs[j].fn(test.b))
 					}
-					require.NoError(t, err)
+					require.Equal(t, &document.ErrIncompatibleTypes{Left: numericFuncs[i].fn(test.a).Type, Right: textFuncs[j].fn(test.b).Type}, err)
 					require.False(t, ok)
 				})
 			}
@@ -412,8 +537,462 @@ func TestComparisonDifferentTypes(t *testing.T) {
 	}
 
 	t.Run("not equal with different types", func(t *testing.T) {
-		ok, err := document.NewIntValue(1).IsNotEqual(document.NewTextValue("foo"))
+		_, err := document.NewIntValue(1).IsNotEqual(document.NewTextValue("foo"))
+		require.Equal(t, &document.ErrIncompatibleTypes{Left: document.Int8Value, Right: document.TextValue}, err)
+	})
+}
+
+func TestComparisonIPs(t *testing.T) {
+	ip := func(s string) document.Value {
+		return mustIPValue(net.ParseIP(s))
+	}
+
+	tests := []struct {
+		op   string
+		a, b document.Value
+		ok   bool
+	}{
+		{"=", ip("10.0.0.1"), ip("10.0.0.1"), true},
+		{"=", ip("10.0.0.1"), mustIPValue(net.IP{10, 0, 0, 1}), true},
+		{"=", ip("10.0.0.1"), ip("10.0.0.2"), false},
+		{">", ip("10.0.0.10"), ip("10.0.0.9"), true},
+		{">", ip("10.0.0.9"), ip("10.0.0.10"), false},
+		{">=", ip("10.0.0.1"), ip("10.0.0.1"), true},
+		{"<", ip("9.255.255.255"), ip("10.0.0.0"), true},
+		{"<", ip("255.255.255.255"), ip("2001:db8::1"), true},
+		{"<", ip("::1"), ip("10.0.0.1"), true},
+		{"<=", ip("2001:db8::1"), ip("2001:db8::1"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s%s%s", test.a, test.op, test.b), func(t *testing.T) {
+			var ok bool
+			var err error
+
+			switch test.op {
+			case "=":
+				ok, err = test.a.IsEqual(test.b)
+			case ">":
+				ok, err = test.a.IsGreaterThan(test.b)
+			case ">=":
+				ok, err = test.a.IsGreaterThanOrEqual(test.b)
+			case "<":
+				ok, err = test.a.IsLesserThan(test.b)
+			case "<=":
+				ok, err = test.a.IsLesserThanOrEqual(test.b)
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ok, ok)
+		})
+	}
+
+	t.Run("different types", func(t *testing.T) {
+		others := []document.Value{
+			document.NewTextValue("10.0.0.1"),
+			document.NewBlobValue(net.IPv4(10, 0, 0, 1)),
+			document.NewIntValue(1),
+			document.NewBoolValue(true),
+		}
+
+		for _, other := range others {
+			_, err := ip("10.0.0.1").IsEqual(other)
+			require.Error(t, err)
+			_, err = other.IsLesserThan(ip("10.0.0.1"))
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		ok, err := ip("10.0.0.1").IsEqual(document.NewNullValue())
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+func TestComparisonUUIDs(t *testing.T) {
+	uuid := func(s string) document.Value {
+		v, err := document.ParseUUIDValue(s)
+		require.NoError(t, err)
+		return v
+	}
+
+	a := uuid("017f22e2-79b0-7cc3-98c4-dc0c0c07398f")
+	b := uuid("017f22e2-79b1-7000-8000-000000000000")
+
+	ok, err := a.IsEqual(uuid("017F22E279B07CC398C4DC0C0C07398F"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = a.IsLesserThan(b)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.IsGreaterThanOrEqual(a)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	t.Run("different types", func(t *testing.T) {
+		_, err := a.IsEqual(document.NewTextValue(a.String()))
+		require.Error(t, err)
+		_, err = document.NewBlobValue(make([]byte, 16)).IsLesserThan(a)
+		require.Error(t, err)
+	})
+
+	t.Run("coercion", func(t *testing.T) {
+		c, err := a.CompareCoerce(document.NewTextValue("017f22e2-79b0-7cc3-98c4-dc0c0c07398f"))
 		require.NoError(t, err)
-		require.True(t, ok)
+		require.Equal(t, 0, c)
+
+		c, err = document.NewTextValue("017f22e2-79b0-7cc3-98c4-dc0c0c07398f").CompareCoerce(b)
+		require.NoError(t, err)
+		require.Equal(t, -1, c)
+
+		_, err = a.CompareCoerce(document.NewTextValue("foo"))
+		require.Error(t, err)
 	})
 }
+
+func TestComparisonBools(t *testing.T) {
+	tests := []struct {
+		op       string
+		a, b     document.Value
+		expected bool
+	}{
+		{"=", document.NewBoolValue(true), document.NewBoolValue(true), true},
+		{"=", document.NewBoolValue(true), document.NewBoolValue(false), false},
+		{"!=", document.NewBoolValue(true), document.NewBoolValue(false), true},
+		{">", document.NewBoolValue(true), document.NewBoolValue(false), true},
+		{">", document.NewBoolValue(false), document.NewBoolValue(false), false},
+		{">=", document.NewBoolValue(false), document.NewBoolValue(false), true},
+		{">=", document.NewBoolValue(false), document.NewBoolValue(true), false},
+		{"<", document.NewBoolValue(false), document.NewBoolValue(true), true},
+		{"<", document.NewBoolValue(true), document.NewBoolValue(true), false},
+		{"<=", document.NewBoolValue(true), document.NewBoolValue(true), true},
+		{"<=", document.NewBoolValue(true), document.NewBoolValue(false), false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v %s %v", test.a, test.op, test.b), func(t *testing.T) {
+			var ok bool
+			var err error
+
+			switch test.op {
+			case "=":
+				ok, err = test.a.IsEqual(test.b)
+			case "!=":
+				ok, err = test.a.IsNotEqual(test.b)
+			case ">":
+				ok, err = test.a.IsGreaterThan(test.b)
+			case ">=":
+				ok, err = test.a.IsGreaterThanOrEqual(test.b)
+			case "<":
+				ok, err = test.a.IsLesserThan(test.b)
+			case "<=":
+				ok, err = test.a.IsLesserThanOrEqual(test.b)
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+
+	t.Run("incompatible types", func(t *testing.T) {
+		// booleans can't be compared with values of other types
+		tests := []struct {
+			a, b document.Value
+		}{
+			{document.NewBoolValue(true), document.NewInt64Value(1)},
+			{document.NewInt8Value(0), document.NewBoolValue(false)},
+			{document.NewFloat64Value(0), document.NewBoolValue(true)},
+			{document.NewBoolValue(false), document.NewTextValue("")},
+		}
+
+		for _, test := range tests {
+			expected := &document.ErrIncompatibleTypes{Left: test.a.Type, Right: test.b.Type}
+
+			ok, err := test.a.IsEqual(test.b)
+			require.Equal(t, expected, err)
+			require.False(t, ok)
+
+			_, err = test.a.IsNotEqual(test.b)
+			require.Equal(t, expected, err)
+
+			ok, err = test.a.IsGreaterThan(test.b)
+			require.Equal(t, expected, err)
+			require.False(t, ok)
+
+			ok, err = test.a.IsLesserThanOrEqual(test.b)
+			require.Equal(t, expected, err)
+			require.False(t, ok)
+		}
+	})
+
+	t.Run("coercion", func(t *testing.T) {
+		c, err := document.NewBoolValue(true).CompareCoerce(document.NewInt64Value(1))
+		require.NoError(t, err)
+		require.Equal(t, 0, c)
+
+		c, err = document.NewInt8Value(0).CompareCoerce(document.NewBoolValue(true))
+		require.NoError(t, err)
+		require.Equal(t, -1, c)
+
+		_, err = document.NewBoolValue(true).CompareCoerce(document.NewInt64Value(2))
+		require.Error(t, err)
+
+		_, err = document.NewBoolValue(true).CompareCoerce(document.NewFloat64Value(1))
+		require.Error(t, err)
+	})
+}
+
+func TestComparisonTimes(t *testing.T) {
+	a := document.NewTimeValue(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := document.NewTimeValue(time.Date(2020, 1, 1, 0, 0, 0, 1, time.UTC))
+
+	ok, err := a.IsEqual(document.NewTimeValue(time.Date(2020, 1, 1, 1, 0, 0, 0, time.FixedZone("", 3600))))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = a.IsLesserThan(b)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.IsGreaterThan(a)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = a.IsGreaterThanOrEqual(b)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	t.Run("different types", func(t *testing.T) {
+		_, err := a.IsEqual(document.NewTextValue(a.String()))
+		require.Error(t, err)
+		_, err = document.NewInt64Value(0).IsLesserThan(a)
+		require.Error(t, err)
+	})
+
+	t.Run("coercion", func(t *testing.T) {
+		c, err := a.CompareCoerce(document.NewTextValue("2020-01-01T00:00:00Z"))
+		require.NoError(t, err)
+		require.Equal(t, 0, c)
+
+		c, err = document.NewTextValue("2020-01-01T00:00:00Z").CompareCoerce(b)
+		require.NoError(t, err)
+		require.Equal(t, -1, c)
+
+		_, err = a.CompareCoerce(document.NewTextValue("foo"))
+		require.Error(t, err)
+	})
+}
+
+func TestCompareCoerce(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     document.Value
+		expected int
+		fails    bool
+	}{
+		{"text = int", document.NewTextValue("10"), document.NewIntValue(10), 0, false},
+		{"text < int", document.NewTextValue("9"), document.NewIntValue(10), -1, false},
+		{"int > text", document.NewIntValue(10), document.NewTextValue("9"), 1, false},
+		{"decimal text > int", document.NewTextValue("10.5"), document.NewIntValue(10), 1, false},
+		{"text with spaces = float", document.NewTextValue(" 1.5 "), document.NewFloat64Value(1.5), 0, false},
+		{"float < text", document.NewFloat64Value(1.5), document.NewTextValue("2"), -1, false},
+		{"text = duration", document.NewTextValue("1s"), document.NewDurationValue(time.Second), 0, false},
+		{"int < int", document.NewIntValue(1), document.NewIntValue(2), -1, false},
+		{"text > text", document.NewTextValue("b"), document.NewTextValue("a"), 1, false},
+		{"bad text", document.NewTextValue("foo"), document.NewIntValue(10), 0, true},
+		{"bad duration", document.NewTextValue("10"), document.NewDurationValue(time.Second), 0, true},
+		{"blob", document.NewBlobValue([]byte("10")), document.NewIntValue(10), 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.a.CompareCoerce(test.b)
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+
+	t.Run("strict comparison is preserved", func(t *testing.T) {
+		ok, err := document.NewTextValue("10").IsEqual(document.NewIntValue(10))
+		require.IsType(t, &document.ErrIncompatibleTypes{}, err)
+		require.False(t, ok)
+	})
+}
+
+func TestValueIsBetween(t *testing.T) {
+	tests := []struct {
+		name         string
+		v            document.Value
+		lower, upper document.Value
+		expected     bool
+		fails        bool
+	}{
+		{"inside", document.NewInt64Value(5), document.NewInt8Value(1), document.NewFloat64Value(10), true, false},
+		{"lower bound", document.NewInt64Value(1), document.NewInt8Value(1), document.NewInt64Value(10), true, false},
+		{"upper bound", document.NewFloat64Value(10), document.NewInt8Value(1), document.NewInt64Value(10), true, false},
+		{"below", document.NewInt64Value(0), document.NewInt8Value(1), document.NewInt64Value(10), false, false},
+		{"above", document.NewInt64Value(11), document.NewInt8Value(1), document.NewInt64Value(10), false, false},
+		{"empty range", document.NewInt64Value(5), document.NewInt64Value(10), document.NewInt64Value(1), false, false},
+		{"texts", document.NewTextValue("b"), document.NewTextValue("a"), document.NewTextValue("c"), true, false},
+		{"null", document.NewNullValue(), document.NewInt64Value(1), document.NewInt64Value(10), false, false},
+		{"incompatible lower bound", document.NewInt64Value(5), document.NewTextValue("a"), document.NewInt64Value(10), false, true},
+		{"incompatible upper bound", document.NewInt64Value(5), document.NewInt64Value(1), document.NewTextValue("a"), false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.v.IsBetween(test.lower, test.upper)
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+}
+
+func TestValueIsIn(t *testing.T) {
+	set := []document.Value{
+		document.NewTextValue("a"),
+		document.NewInt8Value(1),
+		document.NewNullValue(),
+		document.NewTextValue("c"),
+	}
+
+	tests := []struct {
+		name     string
+		v        document.Value
+		others   []document.Value
+		expected bool
+	}{
+		{"text", document.NewTextValue("c"), set, true},
+		{"number of another type", document.NewFloat64Value(1), set, true},
+		{"null", document.NewNullValue(), set, true},
+		{"missing", document.NewTextValue("b"), set, false},
+		{"null missing", document.NewNullValue(), set[:2], false},
+		{"empty set", document.NewTextValue("a"), nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.v.IsIn(test.others)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+}
+
+func TestValueIsEqualFold(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     document.Value
+		expected bool
+	}{
+		{"same case", document.NewTextValue("foo"), document.NewTextValue("foo"), true},
+		{"different case", document.NewTextValue("Foo"), document.NewTextValue("fOO"), true},
+		{"unicode", document.NewTextValue("Éléphant"), document.NewTextValue("éLÉPHANT"), true},
+		{"text and blob", document.NewTextValue("FOO"), document.NewBlobValue([]byte("foo")), true},
+		{"different texts", document.NewTextValue("foo"), document.NewTextValue("foos"), false},
+		{"numbers", document.NewInt8Value(1), document.NewFloat64Value(1), true},
+		{"nulls", document.NewNullValue(), document.NewNullValue(), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.a.IsEqualFold(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+
+	t.Run("incompatible types", func(t *testing.T) {
+		_, err := document.NewTextValue("1").IsEqualFold(document.NewInt64Value(1))
+		require.Error(t, err)
+	})
+}
+
+func TestValueContains(t *testing.T) {
+	arr := document.NewArrayValue(document.NewValueBuffer().
+		Append(document.NewTextValue("go")).
+		Append(document.NewInt64Value(10)).
+		Append(document.NewArrayValue(document.NewValueBuffer().Append(document.NewBoolValue(true)))))
+
+	tests := []struct {
+		name     string
+		v, e     document.Value
+		expected bool
+		fails    bool
+	}{
+		{"text", arr, document.NewTextValue("go"), true, false},
+		{"missing text", arr, document.NewTextValue("rust"), false, false},
+		{"number of another type", arr, document.NewFloat64Value(10), true, false},
+		{"text of a number", arr, document.NewTextValue("10"), false, false},
+		{"array", arr, document.NewArrayValue(document.NewValueBuffer().Append(document.NewBoolValue(true))), true, false},
+		{"empty array", document.NewArrayValue(document.NewValueBuffer()), document.NewNullValue(), false, false},
+		{"not an array", document.NewTextValue("go"), document.NewTextValue("go"), false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := test.v.Contains(test.e)
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+}
+
+func TestStrictEqual(t *testing.T) {
+	doc := func(s string) document.Value {
+		var fb document.FieldBuffer
+		require.NoError(t, json.Unmarshal([]byte(s), &fb))
+		return document.NewDocumentValue(&fb)
+	}
+
+	arr := func(s string) document.Value {
+		var vb document.ValueBuffer
+		require.NoError(t, json.Unmarshal([]byte(s), &vb))
+		return document.NewArrayValue(vb)
+	}
+
+	tests := []struct {
+		name     string
+		a, b     document.Value
+		expected bool
+	}{
+		{"same ints", document.NewInt8Value(1), document.NewInt8Value(1), true},
+		{"different ints", document.NewInt8Value(1), document.NewInt8Value(2), false},
+		{"int8 and int64", document.NewInt8Value(1), document.NewInt64Value(1), false},
+		{"int and float", document.NewInt64Value(1), document.NewFloat64Value(1), false},
+		{"same floats", document.NewFloat64Value(1.5), document.NewFloat64Value(1.5), true},
+		{"NaN", document.NewFloat64Value(math.NaN()), document.NewFloat64Value(math.NaN()), true},
+		{"same texts", document.NewTextValue("a"), document.NewTextValue("a"), true},
+		{"text and blob", document.NewTextValue("a"), document.NewBlobValue([]byte("a")), false},
+		{"nulls", document.NewNullValue(), document.NewNullValue(), true},
+		{"null and zero", document.NewNullValue(), document.NewInt8Value(0), false},
+		{"same bools", document.NewBoolValue(true), document.NewBoolValue(true), true},
+		{"same durations", document.NewDurationValue(time.Second), document.NewDurationValue(time.Second), true},
+		{"same documents", doc(`{"a": 1, "b": [1, 2]}`), doc(`{"a": 1, "b": [1, 2]}`), true},
+		{"documents with different orders", doc(`{"a": 1, "b": 2}`), doc(`{"b": 2, "a": 1}`), false},
+		{"documents with different types", doc(`{"a": 1}`), doc(`{"a": 1.0}`), false},
+		{"documents of different lengths", doc(`{"a": 1}`), doc(`{"a": 1, "b": 2}`), false},
+		{"same arrays", arr(`[1, "a", true]`), arr(`[1, "a", true]`), true},
+		{"arrays of different lengths", arr(`[1, 2]`), arr(`[1]`), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, test.a.StrictEqual(test.b))
+			require.Equal(t, test.expected, test.b.StrictEqual(test.a))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package document
+
+// ChangeKind describes how a field was changed between two documents.
+type ChangeKind uint8
+
+// List of change kinds returned by Diff.
+const (
+	FieldAdded ChangeKind = iota + 1
+	FieldRemoved
+	FieldModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case FieldAdded:
+		return "added"
+	case FieldRemoved:
+		return "removed"
+	case FieldModified:
+		return "modified"
+	}
+
+	return ""
+}
+
+// A FieldChange describes a top-level field that differs between two documents.
+// Old is the value of the field before the change and is empty if the field was added,
+// New is the value after the change and is empty if the field was removed.
+type FieldChange struct {
+	Field string
+	Kind  ChangeKind
+	Old   Value
+	New   Value
+}
+
+// Diff compares the top-level fields of two documents and returns the fields that were added,
+// removed or modified between before and after, sorted by field name.
+// Values are compared using StrictEqual: a field whose value changed type is reported
+// as modified even if both values are equal after conversion.
+func Diff(before, after Document) ([]FieldChange, error) {
+	bfields, err := sortedFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afields, err := sortedFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	var i, j int
+	for i < len(bfields) || j < len(afields) {
+		switch {
+		case j == len(afields) || (i < len(bfields) && bfields[i].name < afields[j].name):
+			changes = append(changes, FieldChange{Field: bfields[i].name, Kind: FieldRemoved, Old: bfields[i].value})
+			i++
+		case i == len(bfields) || afields[j].name < bfields[i].name:
+			changes = append(changes, FieldChange{Field: afields[j].name, Kind: FieldAdded, New: afields[j].value})
+			j++
+		default:
+			if !bfields[i].value.StrictEqual(afields[j].value) {
+				changes = append(changes, FieldChange{Field: bfields[i].name, Kind: FieldModified, Old: bfields[i].value, New: afields[j].value})
+			}
+			i++
+			j++
+		}
+	}
+
+	return changes, nil
+}
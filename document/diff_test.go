@@ -0,0 +1,35 @@
+package document_test
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	before := document.NewFieldBuffer().
+		Add("b", document.NewInt64Value(1)).
+		Add("a", document.NewTextValue("foo")).
+		Add("c", document.NewBoolValue(true)).
+		Add("d", document.NewInt8Value(10))
+
+	after := document.NewFieldBuffer().
+		Add("e", document.NewNullValue()).
+		Add("a", document.NewTextValue("foo")).
+		Add("b", document.NewInt64Value(2)).
+		Add("d", document.NewInt64Value(10))
+
+	changes, err := document.Diff(before, after)
+	require.NoError(t, err)
+	require.Equal(t, []document.FieldChange{
+		{Field: "b", Kind: document.FieldModified, Old: document.NewInt64Value(1), New: document.NewInt64Value(2)},
+		{Field: "c", Kind: document.FieldRemoved, Old: document.NewBoolValue(true)},
+		{Field: "d", Kind: document.FieldModified, Old: document.NewInt8Value(10), New: document.NewInt64Value(10)},
+		{Field: "e", Kind: document.FieldAdded, New: document.NewNullValue()},
+	}, changes)
+
+	changes, err = document.Diff(before, before)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
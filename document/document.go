@@ -2,8 +2,8 @@
 //
 // Comparing values
 //
-// When comparing values, only compatible types can be compared together, otherwise the result
-// of the comparison will always be false.
+// When comparing values, only compatible types can be compared together, otherwise the comparison
+// returns an *ErrIncompatibleTypes error.
 // Here is a list of types than can be compared with each other:
 //
 //   any integer			any integer
@@ -13,13 +13,17 @@
 //   string			bytes
 //   bytes			bytes
 //   bool			bool
-//   null			null
+//   ip			ip
+//   uuid			uuid
+//   time			time
+//   null			any type
 package document
 
 import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
 	"strconv"
@@ -42,6 +46,7 @@ type Document interface {
 
 // NewFromMap creates a document from a map.
 // Due to the way maps are designed, iteration order is not guaranteed.
+// Values are canonicalized, see Value.Canonicalize.
 func NewFromMap(m interface{}) (Document, error) {
 	M := reflect.ValueOf(m)
 	if M.Kind() != reflect.Map || M.Type().Key().Kind() != reflect.String {
@@ -64,7 +69,7 @@ func (m mapDocument) Iterate(fn func(f string, v Value) error) error {
 			return err
 		}
 
-		err = fn(it.Key().String(), v)
+		err = fn(it.Key().String(), v.Canonicalize())
 		if err != nil {
 			return err
 		}
@@ -78,10 +83,28 @@ func (m mapDocument) GetByField(field string) (Value, error) {
 	if v == (reflect.Value{}) {
 		return Value{}, ErrFieldNotFound
 	}
-	return NewValue(v.Interface())
+
+	x, err := NewValue(v.Interface())
+	if err != nil {
+		return x, err
+	}
+
+	return x.Canonicalize(), nil
 }
 
 // NewFromStruct creates a document from a struct using reflection.
+// Field names are the lowercased names of the struct fields, unless a genji struct tag
+// provides a different name. Fields tagged with "-" are ignored.
+// The fields of exported embedded structs, or pointers to structs, are part of the document
+// as if they were fields of the outer struct, unless the embedded field is named by a genji tag,
+// in which case it is stored as a nested document. Embedded pointers that are nil are skipped.
+// It returns an error if two struct fields are associated with the same field name,
+// including fields coming from embedded structs.
+// Fields whose tag has the omitempty option, e.g. `genji:"name,omitempty"` or `genji:",omitempty"`,
+// are omitted from the document if they hold the zero value of their type.
+// Omitted fields don't exist in the stored document: they are indexed as null values,
+// and StructScan sets the fields with the omitempty option that are absent from a document
+// to their zero value. Other absent fields are left untouched by StructScan.
 func NewFromStruct(s interface{}) (Document, error) {
 	ref := reflect.Indirect(reflect.ValueOf(s))
 
@@ -89,38 +112,178 @@ func NewFromStruct(s interface{}) (Document, error) {
 		return nil, errors.New("expected struct or pointer to struct")
 	}
 
-	return structDocument{ref: ref}, nil
+	fields, err := structFields(ref.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return structDocument{ref: ref, fields: fields}, nil
 }
 
 type structDocument struct {
-	ref reflect.Value
+	ref    reflect.Value
+	fields []structFieldInfo
 }
 
 var _ Document = (*structDocument)(nil)
 
-func (s structDocument) Iterate(fn func(f string, v Value) error) error {
-	l := s.ref.NumField()
+// structField returns the name of the document field associated with the struct field
+// and whether it must be omitted when it holds the zero value of its type.
+// It returns false if the struct field must be ignored.
+func structField(sf reflect.StructField) (name string, omitempty bool, ok bool) {
+	gtag, found := sf.Tag.Lookup("genji")
+	if !found {
+		return strings.ToLower(sf.Name), false, true
+	}
 
-	tp := s.ref.Type()
+	if gtag == "-" {
+		return "", false, false
+	}
 
-	for i := 0; i < l; i++ {
-		sf := tp.Field(i)
-		if sf.PkgPath != "" {
-			continue
-		}
+	name = gtag
+	if i := strings.IndexByte(gtag, ','); i >= 0 {
+		name = gtag[:i]
+		omitempty = gtag[i+1:] == "omitempty"
+	}
+
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+
+	return name, omitempty, true
+}
+
+// structFieldInfo associates a document field with a struct field,
+// which may be a field of an embedded struct.
+type structFieldInfo struct {
+	name      string
+	omitempty bool
+	// path of the struct field, e.g. Meta.ID, used in error messages
+	goName string
+	// index sequence of the struct field, as used by reflect.Value.FieldByIndex
+	index []int
+}
+
+// isEmbeddedStruct reports whether the fields of the embedded struct field sf
+// must be flattened into the fields of the outer struct.
+func isEmbeddedStruct(sf reflect.StructField) bool {
+	if !sf.Anonymous {
+		return false
+	}
+
+	tp := sf.Type
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	// times are stored as time values
+	if tp.Kind() != reflect.Struct || tp == timeType {
+		return false
+	}
+
+	// embedded structs named by a tag are regular fields
+	gtag := sf.Tag.Get("genji")
+	if i := strings.IndexByte(gtag, ','); i >= 0 {
+		gtag = gtag[:i]
+	}
+
+	return gtag == ""
+}
+
+// structFields returns the fields of the struct type tp associated with a document field,
+// in order, the fields of embedded structs taking the place of the embedded field.
+// It returns an error if two fields are associated with the same document field.
+func structFields(tp reflect.Type) ([]structFieldInfo, error) {
+	var fields []structFieldInfo
+	names := make(map[string]string, tp.NumField())
+
+	var walk func(st reflect.Type, index []int, prefix string, parents []reflect.Type) error
+	walk = func(st reflect.Type, index []int, prefix string, parents []reflect.Type) error {
+		for i := 0; i < st.NumField(); i++ {
+			sf := st.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+
+			fieldIndex := append(index[:len(index):len(index)], i)
+
+			if isEmbeddedStruct(sf) {
+				etp := sf.Type
+				if etp.Kind() == reflect.Ptr {
+					etp = etp.Elem()
+				}
+
+				// skip structs embedding themselves, directly or not
+				recursive := false
+				for _, p := range parents {
+					recursive = recursive || p == etp
+				}
+				if recursive {
+					continue
+				}
+
+				err := walk(etp, fieldIndex, prefix+sf.Name+".", append(parents, etp))
+				if err != nil {
+					return err
+				}
+				continue
+			}
 
-		var name string
-		if gtag, ok := sf.Tag.Lookup("genji"); ok {
-			if gtag == "-" {
+			name, omitempty, ok := structField(sf)
+			if !ok {
 				continue
 			}
 
-			name = gtag
-		} else {
-			name = strings.ToLower(sf.Name)
+			goName := prefix + sf.Name
+			if other, ok := names[name]; ok {
+				return fmt.Errorf("struct fields %s and %s of %s both map to the document field %q", other, goName, tp, name)
+			}
+			names[name] = goName
+
+			fields = append(fields, structFieldInfo{
+				name:      name,
+				omitempty: omitempty,
+				goName:    goName,
+				index:     fieldIndex,
+			})
+		}
+
+		return nil
+	}
+
+	err := walk(tp, nil, "", []reflect.Type{tp})
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// fieldByIndex returns the field of the struct ref at the given index sequence.
+// It returns false if the field is behind a nil embedded pointer.
+func fieldByIndex(ref reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && ref.Kind() == reflect.Ptr {
+			if ref.IsNil() {
+				return reflect.Value{}, false
+			}
+			ref = ref.Elem()
 		}
+		ref = ref.Field(x)
+	}
 
-		f := s.ref.Field(i)
+	return ref, true
+}
+
+func (s structDocument) Iterate(fn func(f string, v Value) error) error {
+	for _, sf := range s.fields {
+		f, ok := fieldByIndex(s.ref, sf.index)
+		if !ok {
+			continue
+		}
+
+		if sf.omitempty && f.IsZero() {
+			continue
+		}
 
 		v, err := NewValue(f.Interface())
 		if err != nil {
@@ -130,7 +293,7 @@ func (s structDocument) Iterate(fn func(f string, v Value) error) error {
 			return err
 		}
 
-		err = fn(name, v)
+		err = fn(sf.name, v)
 		if err != nil {
 			return err
 		}
@@ -140,34 +303,31 @@ func (s structDocument) Iterate(fn func(f string, v Value) error) error {
 }
 
 func (s structDocument) GetByField(field string) (Value, error) {
-	tp := s.ref.Type()
-
-	var sf reflect.StructField
-	var ok bool
-
-	ln := tp.NumField()
-	for i := 0; i < ln; i++ {
-		sf = tp.Field(i)
-		if gtag, found := sf.Tag.Lookup("genji"); found && gtag == field {
-			ok = true
-			break
+	for _, sf := range s.fields {
+		if sf.name != field {
+			continue
 		}
-		if strings.ToLower(sf.Name) == field {
-			ok = true
-			break
+
+		v, ok := fieldByIndex(s.ref, sf.index)
+		if !ok || (sf.omitempty && v.IsZero()) {
+			return Value{}, ErrFieldNotFound
 		}
-	}
 
-	if !ok || sf.PkgPath != "" {
-		return Value{}, ErrFieldNotFound
+		return NewValue(v.Interface())
 	}
 
-	v := s.ref.FieldByName(sf.Name)
-	if !v.IsValid() {
-		return Value{}, ErrFieldNotFound
+	// fields can also be selected by their lowercased name
+	tp := s.ref.Type()
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		if sf.PkgPath != "" || strings.ToLower(sf.Name) != field {
+			continue
+		}
+
+		return NewValue(s.ref.Field(i).Interface())
 	}
 
-	return NewValue(v.Interface())
+	return Value{}, ErrFieldNotFound
 }
 
 // A Keyer returns the key identifying documents in their storage.
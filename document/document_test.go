@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/document/encoding"
@@ -236,6 +237,19 @@ func TestNewFromMap(t *testing.T) {
 		require.Equal(t, document.ErrFieldNotFound, err)
 	})
 
+	t.Run("Canonical values", func(t *testing.T) {
+		doc, err := document.NewFromMap(map[string]interface{}{"a": 10.0, "b": 10.5})
+		require.NoError(t, err)
+
+		v, err := doc.GetByField("a")
+		require.NoError(t, err)
+		require.Equal(t, document.NewInt8Value(10), v)
+
+		v, err = doc.GetByField("b")
+		require.NoError(t, err)
+		require.Equal(t, document.NewFloat64Value(10.5), v)
+	})
+
 	t.Run("Invalid types", func(t *testing.T) {
 
 		// test NewFromMap rejects invalid types
@@ -290,7 +304,7 @@ func TestNewFromStruct(t *testing.T) {
 
 		AA int `genji:"-"` // ignored
 
-		// embedded fields are not supported currently, they should be ignored
+		// unexported embedded structs should be ignored
 		*group
 
 		// unexported fields should be ignored
@@ -474,6 +488,160 @@ func TestNewFromStruct(t *testing.T) {
 	})
 }
 
+func TestNewFromStructOmitEmpty(t *testing.T) {
+	type user struct {
+		A int    `genji:",omitempty"`
+		B string `genji:"b-b,omitempty"`
+		C *int   `genji:"c,omitempty"`
+		D int
+	}
+
+	fields := func(u *user) []string {
+		doc, err := document.NewFromStruct(u)
+		require.NoError(t, err)
+
+		var names []string
+		err = doc.Iterate(func(f string, v document.Value) error {
+			names = append(names, f)
+			return nil
+		})
+		require.NoError(t, err)
+		return names
+	}
+
+	require.Equal(t, []string{"d"}, fields(&user{}))
+	c := 0
+	require.Equal(t, []string{"a", "b-b", "c", "d"}, fields(&user{A: 1, B: "foo", C: &c}))
+
+	doc, err := document.NewFromStruct(&user{B: "foo"})
+	require.NoError(t, err)
+	_, err = doc.GetByField("a")
+	require.Equal(t, document.ErrFieldNotFound, err)
+	v, err := doc.GetByField("b-b")
+	require.NoError(t, err)
+	require.Equal(t, document.NewTextValue("foo"), v)
+	// renamed fields can still be selected by their lowercased name
+	v, err = doc.GetByField("b")
+	require.NoError(t, err)
+	require.Equal(t, document.NewTextValue("foo"), v)
+
+	// absent omitempty fields are set to their zero value
+	c = 10
+	u := user{A: 10, C: &c, D: 10}
+	err = document.StructScan(doc, &u)
+	require.NoError(t, err)
+	require.Equal(t, user{B: "foo"}, u)
+}
+
+func TestNewFromStructDuplicateFields(t *testing.T) {
+	type user struct {
+		UserID int `genji:"user_id"`
+		ID     int `genji:"user_id"`
+	}
+
+	_, err := document.NewFromStruct(&user{})
+	require.EqualError(t, err, `struct fields UserID and ID of document_test.user both map to the document field "user_id"`)
+
+	err = document.StructScan(document.NewFieldBuffer(), &user{})
+	require.Error(t, err)
+
+	// a tag can reuse the default name of an ignored field
+	type other struct {
+		ID     int `genji:"-"`
+		UserID int `genji:"id"`
+	}
+
+	_, err = document.NewFromStruct(&other{})
+	require.NoError(t, err)
+}
+
+type Meta struct {
+	ID        int
+	CreatedAt time.Time
+}
+
+type Audit struct {
+	UpdatedBy string
+}
+
+func TestNewFromStructEmbedded(t *testing.T) {
+	type user struct {
+		Meta
+		*Audit
+		Name   string
+		Parent Meta `genji:"parent"`
+		Nested Meta `genji:"nested"`
+	}
+
+	now := time.Now().UTC()
+	u := user{
+		Meta:   Meta{ID: 10, CreatedAt: now},
+		Name:   "foo",
+		Parent: Meta{ID: 1},
+	}
+
+	t.Run("Iterate", func(t *testing.T) {
+		doc, err := document.NewFromStruct(&u)
+		require.NoError(t, err)
+
+		var fields []string
+		err = doc.Iterate(func(f string, v document.Value) error {
+			fields = append(fields, f)
+			return nil
+		})
+		require.NoError(t, err)
+		// the fields of the nil embedded pointer are skipped
+		require.Equal(t, []string{"id", "createdat", "name", "parent", "nested"}, fields)
+
+		v, err := doc.GetByField("id")
+		require.NoError(t, err)
+		require.Equal(t, document.NewInt8Value(10), v)
+
+		_, err = doc.GetByField("updatedby")
+		require.Equal(t, document.ErrFieldNotFound, err)
+
+		v, err = doc.GetByField("parent")
+		require.NoError(t, err)
+		require.Equal(t, document.DocumentValue, v.Type)
+
+		u.Audit = &Audit{UpdatedBy: "bar"}
+		defer func() { u.Audit = nil }()
+		v, err = doc.GetByField("updatedby")
+		require.NoError(t, err)
+		require.Equal(t, document.NewTextValue("bar"), v)
+	})
+
+	t.Run("StructScan", func(t *testing.T) {
+		doc := document.NewFieldBuffer().
+			Add("id", document.NewInt64Value(10)).
+			Add("createdat", document.NewTimeValue(now)).
+			Add("updatedby", document.NewTextValue("bar")).
+			Add("name", document.NewTextValue("foo"))
+
+		var res user
+		err := document.StructScan(doc, &res)
+		require.NoError(t, err)
+		require.Equal(t, user{
+			Meta:  Meta{ID: 10, CreatedAt: now},
+			Audit: &Audit{UpdatedBy: "bar"},
+			Name:  "foo",
+		}, res)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		type other struct {
+			Meta
+			ID int
+		}
+
+		_, err := document.NewFromStruct(&other{})
+		require.EqualError(t, err, `struct fields Meta.ID and ID of document_test.other both map to the document field "id"`)
+
+		err = document.StructScan(document.NewFieldBuffer(), &other{})
+		require.Error(t, err)
+	})
+}
+
 type foo struct {
 	A string
 	B int
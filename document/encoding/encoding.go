@@ -12,6 +12,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"math"
+	"net"
 	"time"
 
 	"github.com/asdine/genji/document"
@@ -197,7 +198,8 @@ func DecodeInt64(buf []byte) (int64, error) {
 // EncodeFloat64 takes an float64 and returns its binary representation.
 func EncodeFloat64(x float64) []byte {
 	fb := math.Float64bits(x)
-	if x >= 0 {
+	// relying on the sign bit rather than on the value ensures NaN can be decoded
+	if fb&(1<<63) == 0 {
 		fb ^= 1 << 63
 	} else {
 		fb ^= 1<<64 - 1
@@ -217,6 +219,64 @@ func DecodeFloat64(buf []byte) (float64, error) {
 	return math.Float64frombits(x), nil
 }
 
+// EncodeIP takes an IP address and returns its 16-byte representation.
+// IPv4 addresses are encoded in their IPv4-mapped IPv6 form, so that
+// IPv4 and IPv6 addresses sort consistently.
+func EncodeIP(x net.IP) []byte {
+	return x.To16()
+}
+
+// DecodeIP takes a byte slice and decodes it into an IP address.
+func DecodeIP(buf []byte) (net.IP, error) {
+	if len(buf) != net.IPv6len {
+		return nil, errors.New("cannot decode buffer to ip")
+	}
+
+	return net.IP(buf), nil
+}
+
+// EncodeUUID takes a UUID and returns its 16-byte representation.
+func EncodeUUID(x [16]byte) []byte {
+	return x[:]
+}
+
+// DecodeUUID takes a byte slice and decodes it into a UUID.
+func DecodeUUID(buf []byte) ([16]byte, error) {
+	var u [16]byte
+	if len(buf) != len(u) {
+		return u, errors.New("cannot decode buffer to uuid")
+	}
+
+	copy(u[:], buf)
+	return u, nil
+}
+
+// EncodeTime takes a time and returns its 12-byte representation:
+// the number of seconds since the Unix epoch, encoded like an int64,
+// followed by the nanoseconds within the second.
+// Unlike a number of nanoseconds, this representation covers the full range of time.Time.
+// The location of the time is not stored, decoded times are in UTC.
+func EncodeTime(x time.Time) []byte {
+	buf := make([]byte, 12)
+	copy(buf, EncodeInt64(x.Unix()))
+	binary.BigEndian.PutUint32(buf[8:], uint32(x.Nanosecond()))
+	return buf
+}
+
+// DecodeTime takes a byte slice and decodes it into a time.
+func DecodeTime(buf []byte) (time.Time, error) {
+	if len(buf) != 12 {
+		return time.Time{}, errors.New("cannot decode buffer to time")
+	}
+
+	sec, err := DecodeInt64(buf[:8])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(sec, int64(binary.BigEndian.Uint32(buf[8:]))).UTC(), nil
+}
+
 // EncodeDocument takes a document and encodes it using the encoding.Format type.
 func EncodeDocument(d document.Document) ([]byte, error) {
 	if ec, ok := d.(EncodedDocument); ok {
@@ -319,6 +379,12 @@ func EncodeValue(v document.Value) ([]byte, error) {
 		return EncodeFloat64(v.V.(float64)), nil
 	case document.DurationValue:
 		return EncodeInt64(int64(v.V.(time.Duration))), nil
+	case document.IPValue:
+		return EncodeIP(v.V.(net.IP)), nil
+	case document.UUIDValue:
+		return EncodeUUID(v.V.([16]byte)), nil
+	case document.TimeValue:
+		return EncodeTime(v.V.(time.Time)), nil
 	case document.NullValue:
 		return nil, nil
 	}
@@ -546,6 +612,24 @@ func DecodeValue(t document.ValueType, data []byte) (document.Value, error) {
 			return document.Value{}, err
 		}
 		return document.NewDurationValue(time.Duration(x)), nil
+	case document.IPValue:
+		x, err := DecodeIP(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		return document.NewIPValue(x)
+	case document.UUIDValue:
+		x, err := DecodeUUID(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		return document.NewUUIDValue(x), nil
+	case document.TimeValue:
+		x, err := DecodeTime(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		return document.NewTimeValue(x), nil
 	case document.NullValue:
 		return document.NewNullValue(), nil
 	}
@@ -3,6 +3,9 @@ package encoding
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"math/rand"
+	"net"
 	"testing"
 	"time"
 
@@ -224,6 +227,41 @@ func BenchmarkGetByField(b *testing.B) {
 	}
 }
 
+// BenchmarkSingleFieldAccess compares reading a single field of a 20-field document
+// lazily with decoding the whole document.
+func BenchmarkSingleFieldAccess(b *testing.B) {
+	var buf document.FieldBuffer
+
+	for i := int64(0); i < 20; i++ {
+		buf.Add(fmt.Sprintf("name-%d", i), document.NewInt64Value(i))
+	}
+
+	data, err := EncodeDocument(&buf)
+	require.NoError(b, err)
+
+	b.Run("GetByField", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			DecodeDocument(data).GetByField("name-10")
+		}
+	})
+
+	b.Run("Iterate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			DecodeDocument(data).Iterate(func(string, document.Value) error {
+				return nil
+			})
+		}
+	})
+
+	b.Run("Copy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var fb document.FieldBuffer
+			fb.Copy(DecodeDocument(data))
+			fb.GetByField("name-10")
+		}
+	})
+}
+
 func BenchmarkDecodeDocument(b *testing.B) {
 	var buf document.FieldBuffer
 
@@ -265,6 +303,8 @@ func TestValueEncodeDecode(t *testing.T) {
 		{"int32", int32(-10), func() []byte { return EncodeInt32(-10) }, func(buf []byte) (interface{}, error) { return DecodeInt32(buf) }},
 		{"int64", int64(-10), func() []byte { return EncodeInt64(-10) }, func(buf []byte) (interface{}, error) { return DecodeInt64(buf) }},
 		{"float64", float64(-3.14), func() []byte { return EncodeFloat64(-3.14) }, func(buf []byte) (interface{}, error) { return DecodeFloat64(buf) }},
+		{"ipv4", net.ParseIP("10.0.0.1"), func() []byte { return EncodeIP(net.IPv4(10, 0, 0, 1)) }, func(buf []byte) (interface{}, error) { return DecodeIP(buf) }},
+		{"ipv6", net.ParseIP("2001:db8::1"), func() []byte { return EncodeIP(net.ParseIP("2001:db8::1")) }, func(buf []byte) (interface{}, error) { return DecodeIP(buf) }},
 	}
 
 	for _, test := range tests {
@@ -296,6 +336,7 @@ func TestOrdering(t *testing.T) {
 		{"int32", -1000, 1000, func(i int) []byte { return EncodeInt32(int32(i)) }},
 		{"int64", -1000, 1000, func(i int) []byte { return EncodeInt64(int64(i)) }},
 		{"float64", -1000, 1000, func(i int) []byte { return EncodeFloat64(float64(i)) }},
+		{"ip", 0, 1000, func(i int) []byte { return EncodeIP(net.IPv4(10, 0, byte(i>>8), byte(i))) }},
 	}
 
 	for _, test := range tests {
@@ -314,3 +355,179 @@ func TestOrdering(t *testing.T) {
 		})
 	}
 }
+
+// randomValues returns boundary values and randomly generated values of every type
+// whose encoding preserves ordering.
+func randomValues(r *rand.Rand, n int) map[document.ValueType][]document.Value {
+	str := func() []byte {
+		b := make([]byte, r.Intn(8))
+		r.Read(b)
+		return b
+	}
+
+	values := map[document.ValueType][]document.Value{
+		document.BlobValue:  {document.NewBlobValue([]byte{}), document.NewBlobValue([]byte{0}), document.NewBlobValue([]byte{0xFF})},
+		document.TextValue:  {document.NewTextValue(""), document.NewTextValue("\x00"), document.NewTextValue("é")},
+		document.BoolValue:  {document.NewBoolValue(false), document.NewBoolValue(true)},
+		document.Int8Value:  {document.NewInt8Value(math.MinInt8), document.NewInt8Value(0), document.NewInt8Value(math.MaxInt8)},
+		document.Int16Value: {document.NewInt16Value(math.MinInt16), document.NewInt16Value(0), document.NewInt16Value(math.MaxInt16)},
+		document.Int32Value: {document.NewInt32Value(math.MinInt32), document.NewInt32Value(0), document.NewInt32Value(math.MaxInt32)},
+		document.Int64Value: {document.NewInt64Value(math.MinInt64), document.NewInt64Value(0), document.NewInt64Value(math.MaxInt64)},
+		document.Float64Value: {
+			document.NewFloat64Value(math.Inf(-1)), document.NewFloat64Value(-math.MaxFloat64), document.NewFloat64Value(-math.SmallestNonzeroFloat64),
+			document.NewFloat64Value(math.Copysign(0, -1)), document.NewFloat64Value(0),
+			document.NewFloat64Value(math.SmallestNonzeroFloat64), document.NewFloat64Value(math.MaxFloat64), document.NewFloat64Value(math.Inf(1)),
+			document.NewFloat64Value(math.NaN()), document.NewFloat64Value(math.Copysign(math.NaN(), -1)),
+		},
+		document.DurationValue: {document.NewDurationValue(math.MinInt64), document.NewDurationValue(0), document.NewDurationValue(math.MaxInt64)},
+		document.IPValue:       {mustIPValue(net.IPv6zero), mustIPValue(net.IPv4zero), mustIPValue(net.IPv4bcast)},
+		document.UUIDValue:     {document.NewUUIDValue([16]byte{}), document.NewUUIDValue([16]byte{15: 1}), document.NewUUIDValue([16]byte{0: 0xff})},
+		document.TimeValue: {
+			document.NewTimeValue(time.Time{}), document.NewTimeValue(time.Unix(-1, 999999999)), document.NewTimeValue(time.Unix(0, 0)),
+			document.NewTimeValue(time.Unix(0, 1)), document.NewTimeValue(time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC)),
+		},
+	}
+
+	for i := 0; i < n; i++ {
+		values[document.BlobValue] = append(values[document.BlobValue], document.NewBlobValue(str()))
+		values[document.TextValue] = append(values[document.TextValue], document.NewTextValue(string(str())))
+		values[document.BoolValue] = append(values[document.BoolValue], document.NewBoolValue(r.Intn(2) == 0))
+		values[document.Int8Value] = append(values[document.Int8Value], document.NewInt8Value(int8(r.Uint32())))
+		values[document.Int16Value] = append(values[document.Int16Value], document.NewInt16Value(int16(r.Uint32())))
+		values[document.Int32Value] = append(values[document.Int32Value], document.NewInt32Value(int32(r.Uint32())))
+		values[document.Int64Value] = append(values[document.Int64Value], document.NewInt64Value(int64(r.Uint64())))
+		values[document.DurationValue] = append(values[document.DurationValue], document.NewDurationValue(time.Duration(r.Uint64())))
+
+		f := r.NormFloat64() * math.Pow(10, float64(r.Intn(20)))
+		if i%2 == 0 {
+			f = math.Float64frombits(r.Uint64())
+		}
+		if !math.IsNaN(f) {
+			values[document.Float64Value] = append(values[document.Float64Value], document.NewFloat64Value(f))
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		r.Read(ip)
+		if i%2 == 0 {
+			ip = net.IPv4(ip[0], ip[1], ip[2], ip[3])
+		}
+		values[document.IPValue] = append(values[document.IPValue], mustIPValue(ip))
+
+		var u [16]byte
+		r.Read(u[:])
+		values[document.UUIDValue] = append(values[document.UUIDValue], document.NewUUIDValue(u))
+
+		tm := time.Unix(r.Int63n(1<<36)-1<<35, r.Int63n(int64(time.Second)))
+		values[document.TimeValue] = append(values[document.TimeValue], document.NewTimeValue(tm))
+	}
+
+	return values
+}
+
+func TestRandomValuesRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	for tp, values := range randomValues(r, 200) {
+		t.Run(tp.String(), func(t *testing.T) {
+			for _, v := range values {
+				buf, err := EncodeValue(v)
+				require.NoError(t, err)
+
+				got, err := DecodeValue(v.Type, buf)
+				require.NoError(t, err)
+
+				ok, err := got.IsEqual(v)
+				require.NoError(t, err)
+				require.True(t, ok, "%v decoded as %v", v, got)
+			}
+		})
+	}
+
+	t.Run("NaN and zeros", func(t *testing.T) {
+		for _, f := range []float64{math.NaN(), math.Copysign(math.NaN(), -1), math.Float64frombits(0x7FF0000000000001), math.Copysign(0, -1), 0} {
+			got, err := DecodeFloat64(EncodeFloat64(f))
+			require.NoError(t, err)
+			// the sign of zeros and the payload of NaNs are preserved
+			require.Equal(t, math.Float64bits(f), math.Float64bits(got))
+		}
+	})
+
+	t.Run("uint64 above MaxInt64", func(t *testing.T) {
+		_, err := document.NewValue(uint64(math.MaxInt64) + 1)
+		require.Error(t, err)
+	})
+}
+
+func TestRandomValuesOrdering(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	cmp := func(t *testing.T, a, b document.Value) int {
+		ok, err := a.IsLesserThan(b)
+		require.NoError(t, err)
+		if ok {
+			return -1
+		}
+
+		ok, err = a.IsEqual(b)
+		require.NoError(t, err)
+		if ok {
+			return 0
+		}
+
+		ok, err = a.IsGreaterThan(b)
+		require.NoError(t, err)
+		require.True(t, ok, "%v and %v are not ordered", a, b)
+		return 1
+	}
+
+	for tp, values := range randomValues(r, 100) {
+		t.Run(tp.String(), func(t *testing.T) {
+			encoded := make([][]byte, len(values))
+			for i, v := range values {
+				var err error
+				encoded[i], err = EncodeValue(v)
+				require.NoError(t, err)
+			}
+
+			// the encoding of floats preserves the sign of zeros and the payload of NaNs,
+			// which are only normalized in index keys, so their encodings aren't ordered
+			normalized := func(v document.Value) bool {
+				f, ok := v.V.(float64)
+				return !ok || (f != 0 && !math.IsNaN(f))
+			}
+
+			for i := range values {
+				for j := range values {
+					if !normalized(values[i]) || !normalized(values[j]) {
+						continue
+					}
+
+					c := cmp(t, values[i], values[j])
+
+					// antisymmetry
+					require.Equal(t, -c, cmp(t, values[j], values[i]))
+					// agreement with the byte ordering of the encoded values
+					require.Equal(t, c, bytes.Compare(encoded[i], encoded[j]), "%v and %v", values[i], values[j])
+				}
+			}
+
+			// transitivity, sampled over random triples
+			for n := 0; n < 1000; n++ {
+				a, b, c := values[r.Intn(len(values))], values[r.Intn(len(values))], values[r.Intn(len(values))]
+				if cmp(t, a, b) <= 0 && cmp(t, b, c) <= 0 {
+					require.True(t, cmp(t, a, c) <= 0, "%v <= %v <= %v", a, b, c)
+				}
+			}
+		})
+	}
+}
+
+// mustIPValue returns the IP value of ip and panics if it is invalid.
+func mustIPValue(ip net.IP) document.Value {
+	v, err := document.NewIPValue(ip)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
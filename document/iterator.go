@@ -14,6 +14,15 @@ type Iterator interface {
 	Iterate(func(d Document) error) error
 }
 
+// A ReversibleIterator is an iterator that can also go through its documents in reverse order.
+type ReversibleIterator interface {
+	Iterator
+
+	// Reverse returns an iterator that goes through the same documents in reverse order,
+	// or nil if it can't be done without reading all the documents first.
+	Reverse() Iterator
+}
+
 // NewIterator creates an iterator that iterates over documents.
 func NewIterator(documents ...Document) Iterator {
 	return documentsIterator(documents)
@@ -39,6 +48,8 @@ func (rr documentsIterator) Iterate(fn func(d Document) error) error {
 type Stream struct {
 	it Iterator
 	op StreamOperator
+	// orderless is true if op doesn't depend on the order of the documents.
+	orderless bool
 }
 
 // NewStream creates a stream using the given iterator.
@@ -94,19 +105,49 @@ func (s Stream) Pipe(op StreamOperator) Stream {
 	}
 }
 
+// Reverse returns a stream that goes through the documents of s in reverse order.
+// It returns nil if the underlying iterator can't be reversed or if s was transformed
+// by an operator that depends on the order of the documents, like Limit or Offset.
+// Only the Map and Filter operators are considered independent of the order.
+// It implements the ReversibleIterator interface.
+func (s Stream) Reverse() Iterator {
+	if s.op != nil && !s.orderless {
+		return nil
+	}
+
+	if s.it == nil {
+		return s
+	}
+
+	r, ok := s.it.(ReversibleIterator)
+	if !ok {
+		return nil
+	}
+
+	it := r.Reverse()
+	if it == nil {
+		return nil
+	}
+
+	s.it = it
+	return s
+}
+
 // Map applies fn to each received document and passes it to the next stream.
 // If fn returns an error, the stream is interrupted.
 func (s Stream) Map(fn func(d Document) (Document, error)) Stream {
-	return s.Pipe(func() func(d Document) (Document, error) {
+	st := s.Pipe(func() func(d Document) (Document, error) {
 		return fn
 	})
+	st.orderless = true
+	return st
 }
 
 // Filter each received document using fn.
 // If fn returns true, the document is kept, otherwise it is skipped.
 // If fn returns an error, the stream is interrupted.
 func (s Stream) Filter(fn func(d Document) (bool, error)) Stream {
-	return s.Pipe(func() func(d Document) (Document, error) {
+	st := s.Pipe(func() func(d Document) (Document, error) {
 		return func(d Document) (Document, error) {
 			ok, err := fn(d)
 			if err != nil {
@@ -120,6 +161,8 @@ func (s Stream) Filter(fn func(d Document) (bool, error)) Stream {
 			return d, nil
 		}
 	})
+	st.orderless = true
+	return st
 }
 
 // Limit interrupts the stream once the number of passed documents have reached n.
@@ -195,6 +238,23 @@ func (s Stream) First() (d Document, err error) {
 	return
 }
 
+// Last runs the stream and returns the last document found.
+// If the stream can be reversed, only its last document is read.
+// Otherwise, documents are not buffered: every document but the last one is discarded as soon as the next one is read.
+// If the stream is empty, all return values are nil.
+func (s Stream) Last() (d Document, err error) {
+	if it := s.Reverse(); it != nil {
+		return NewStream(it).First()
+	}
+
+	err = s.Iterate(func(doc Document) error {
+		d = doc
+		return nil
+	})
+
+	return
+}
+
 // An StreamOperator is used to modify a stream.
 // If a stream operator returns a document, it will be passed to the next stream.
 // If it returns a nil document, the document will be ignored.
@@ -51,6 +51,50 @@ func ExampleStream_First() {
 	// 10 foo 15
 }
 
+func ExampleStream_Last() {
+	db, err := genji.Open(":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE user")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = db.Exec("INSERT INTO user (id, name) VALUES (?, ?)", i, fmt.Sprintf("foo%d", i))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	result, err := db.Query("SELECT id, name FROM user ORDER BY id")
+	if err != nil {
+		panic(err)
+	}
+	defer result.Close()
+
+	d, err := result.Last()
+	if err != nil {
+		panic(err)
+	}
+
+	var id uint64
+	var name string
+
+	err = document.Scan(d, &id, &name)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(id, name)
+
+	// Output:
+	// 3 foo3
+}
+
 func ExampleStream_Iterate() {
 	type User struct {
 		ID      int64
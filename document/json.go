@@ -3,25 +3,31 @@ package document
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"strconv"
+	"strings"
 )
 
 // ToJSON encodes d to w in JSON.
 func ToJSON(w io.Writer, d Document) error {
-	return json.NewEncoder(w).Encode(jsonDocument{d})
+	return json.NewEncoder(w).Encode(jsonDocument{Document: d})
 }
 
 // ArrayToJSON encodes a to w in JSON.
 func ArrayToJSON(w io.Writer, a Array) error {
-	return json.NewEncoder(w).Encode(jsonArray{a})
+	return json.NewEncoder(w).Encode(jsonArray{Array: a})
 }
 
 type jsonArray struct {
 	Array
+
+	// typed reports whether values must be encoded with their type,
+	// as done by Value.MarshalJSON.
+	typed bool
 }
 
 func (j jsonArray) MarshalJSON() ([]byte, error) {
@@ -35,7 +41,7 @@ func (j jsonArray) MarshalJSON() ([]byte, error) {
 		}
 		notFirst = true
 
-		data, err := v.MarshalJSON()
+		data, err := v.marshalJSON(j.typed)
 		if err != nil {
 			return err
 		}
@@ -52,6 +58,10 @@ func (j jsonArray) MarshalJSON() ([]byte, error) {
 
 type jsonDocument struct {
 	Document
+
+	// typed reports whether values must be encoded with their type,
+	// as done by Value.MarshalJSON.
+	typed bool
 }
 
 func (j jsonDocument) MarshalJSON() ([]byte, error) {
@@ -69,7 +79,7 @@ func (j jsonDocument) MarshalJSON() ([]byte, error) {
 		buf.WriteString(strconv.Quote(f))
 		buf.WriteRune(':')
 
-		data, err := v.MarshalJSON()
+		data, err := v.marshalJSON(j.typed)
 		if err != nil {
 			return err
 		}
@@ -217,13 +227,121 @@ func parseJSONArray(dec *json.Decoder, currToken json.Token, buf *ValueBuffer) e
 	return nil
 }
 
+// jsonTypeField reports whether d has exactly one field whose name is a dollar sign
+// followed by the name of a type, as generated by Value.MarshalJSON.
+// If so, it returns that type and the value of the field.
+func jsonTypeField(d Document) (ValueType, Value, bool, error) {
+	var name string
+	var payload Value
+	var n int
+
+	err := d.Iterate(func(f string, v Value) error {
+		name, payload = f, v
+		n++
+		return nil
+	})
+	if err != nil || n != 1 || !strings.HasPrefix(name, "$") {
+		return 0, Value{}, false, err
+	}
+
+	t, err := ParseValueType(name[1:])
+	if err != nil {
+		return 0, Value{}, false, nil
+	}
+
+	return t, payload, true, nil
+}
+
+// decodeTypedJSON converts the values of v that were encoded with their type
+// by Value.MarshalJSON back to that type.
+func decodeTypedJSON(v Value) (Value, error) {
+	switch v.Type {
+	case DocumentValue:
+		d := v.V.(Document)
+		t, payload, ok, err := jsonTypeField(d)
+		if err != nil {
+			return Value{}, err
+		}
+		if ok {
+			return decodeTypedJSONField(t, payload)
+		}
+
+		return decodeTypedJSONDocument(d)
+	case ArrayValue:
+		buf := NewValueBuffer()
+		err := v.V.(Array).Iterate(func(i int, v Value) error {
+			v, err := decodeTypedJSON(v)
+			if err != nil {
+				return err
+			}
+
+			buf = buf.Append(v)
+			return nil
+		})
+		if err != nil {
+			return Value{}, err
+		}
+
+		return NewArrayValue(buf), nil
+	}
+
+	return v, nil
+}
+
+func decodeTypedJSONDocument(d Document) (Value, error) {
+	fb := NewFieldBuffer()
+	err := d.Iterate(func(f string, v Value) error {
+		v, err := decodeTypedJSON(v)
+		if err != nil {
+			return err
+		}
+
+		fb.Add(f, v)
+		return nil
+	})
+	if err != nil {
+		return Value{}, err
+	}
+
+	return NewDocumentValue(fb), nil
+}
+
+func decodeTypedJSONField(t ValueType, payload Value) (Value, error) {
+	switch t {
+	case DocumentValue:
+		if payload.Type != DocumentValue {
+			return Value{}, fmt.Errorf("found %s, expected document", payload.Type)
+		}
+
+		return decodeTypedJSONDocument(payload.V.(Document))
+	case BlobValue:
+		if payload.Type != TextValue {
+			return Value{}, fmt.Errorf("found %s, expected base64 encoded text", payload.Type)
+		}
+
+		b, err := base64.StdEncoding.DecodeString(string(payload.V.([]byte)))
+		if err != nil {
+			return Value{}, err
+		}
+
+		return NewBlobValue(b), nil
+	}
+
+	payload, err := decodeTypedJSON(payload)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return payload.ConvertTo(t)
+}
+
 // IteratorToJSON encodes all the documents of an iterator to JSON stream.
 func IteratorToJSON(w io.Writer, s Iterator) error {
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 
 	return s.Iterate(func(d Document) error {
-		return enc.Encode(jsonDocument{d})
+		return enc.Encode(jsonDocument{Document: d})
 	})
 }
 
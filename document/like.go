@@ -0,0 +1,105 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Matches reports whether v matches the given SQL LIKE pattern.
+// In the pattern, % matches any sequence of characters, including an empty one,
+// and _ matches exactly one character. A backslash escapes the character that follows,
+// so that \% and \_ match a literal % and _, and \\ a literal backslash.
+// The whole value must match the pattern, so an empty pattern only matches an empty text.
+// Matching is case sensitive. It returns an error if v is not a text or a blob,
+// or if the pattern ends with an unescaped backslash.
+func (v Value) Matches(pattern string) (bool, error) {
+	if v.Type != TextValue && v.Type != BlobValue {
+		return false, fmt.Errorf("cannot match a pattern against a %s", v.Type)
+	}
+
+	p, err := compileLikePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return p.match([]rune(string(v.V.([]byte)))), nil
+}
+
+const (
+	likeLiteral byte = iota
+	likeAnyChar
+	likeAnySequence
+)
+
+type likeToken struct {
+	kind byte
+	r    rune
+}
+
+type likePattern []likeToken
+
+func compileLikePattern(pattern string) (likePattern, error) {
+	var p likePattern
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			p = append(p, likeToken{kind: likeLiteral, r: r})
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			// consecutive % are equivalent to a single one
+			if len(p) == 0 || p[len(p)-1].kind != likeAnySequence {
+				p = append(p, likeToken{kind: likeAnySequence})
+			}
+		case r == '_':
+			p = append(p, likeToken{kind: likeAnyChar})
+		default:
+			p = append(p, likeToken{kind: likeLiteral, r: r})
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("pattern ends with an unescaped backslash")
+	}
+
+	return p, nil
+}
+
+// match reports whether the pattern matches the whole of s.
+// When a character doesn't match, it backtracks to the last % seen
+// and lets it consume one more character.
+func (p likePattern) match(s []rune) bool {
+	var si, pi int
+	star, mark := -1, 0
+
+	for si < len(s) {
+		if pi < len(p) {
+			switch t := p[pi]; {
+			case t.kind == likeAnyChar, t.kind == likeLiteral && t.r == s[si]:
+				si++
+				pi++
+				continue
+			case t.kind == likeAnySequence:
+				star, mark = pi, si
+				pi++
+				continue
+			}
+		}
+
+		if star == -1 {
+			return false
+		}
+
+		mark++
+		pi, si = star+1, mark
+	}
+
+	for pi < len(p) && p[pi].kind == likeAnySequence {
+		pi++
+	}
+
+	return pi == len(p)
+}
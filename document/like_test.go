@@ -0,0 +1,82 @@
+package document_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueMatches(t *testing.T) {
+	tests := []struct {
+		value    string
+		pattern  string
+		expected bool
+	}{
+		{"foobar", "foobar", true},
+		{"foobar", "foo", false},
+		{"foobar", "Foobar", false},
+		// trailing wildcards
+		{"foobar", "foo%", true},
+		{"foo", "foo%", true},
+		{"fo", "foo%", false},
+		{"foobar", "fooba_", true},
+		{"fooba", "fooba_", false},
+		// leading wildcards
+		{"foobar", "%bar", true},
+		{"bar", "%bar", true},
+		{"foobars", "%bar", false},
+		{"foobar", "_oobar", true},
+		// embedded wildcards
+		{"foobar", "f%r", true},
+		{"foobazbar", "f%bar", true},
+		{"foobar", "f%o%a%", true},
+		{"foobar", "f%x%", false},
+		{"foobar", "f__b_r", true},
+		{"foobar", "f_b%", false},
+		{"aaab", "%a%ab", true},
+		{"héllo", "h_llo", true},
+		// only wildcards
+		{"", "%", true},
+		{"foo", "%", true},
+		{"foo", "%%", true},
+		{"", "_", false},
+		{"foo", "___", true},
+		// empty pattern
+		{"", "", true},
+		{"foo", "", false},
+		// escaped wildcards
+		{"100%", `100\%`, true},
+		{"1000", `100\%`, false},
+		{"a_b", `a\_b`, true},
+		{"axb", `a\_b`, false},
+		{`a\b`, `a\\b`, true},
+		{"a%b", `a\%%`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%q LIKE %q", test.value, test.pattern), func(t *testing.T) {
+			ok, err := document.NewTextValue(test.value).Matches(test.pattern)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+
+			ok, err = document.NewBlobValue([]byte(test.value)).Matches(test.pattern)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ok)
+		})
+	}
+
+	t.Run("trailing backslash", func(t *testing.T) {
+		_, err := document.NewTextValue("foo").Matches(`foo\`)
+		require.Error(t, err)
+	})
+
+	t.Run("not a string", func(t *testing.T) {
+		_, err := document.NewInt64Value(10).Matches("10")
+		require.Error(t, err)
+
+		_, err = document.NewNullValue().Matches("%")
+		require.Error(t, err)
+	})
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"time"
 )
 
 // A Scanner can iterate over a document and scan all the fields.
@@ -62,30 +62,27 @@ func structScan(d Document, ref reflect.Value) error {
 	}
 
 	sref := reflect.Indirect(ref)
-	stp := sref.Type()
-	l := sref.NumField()
-	for i := 0; i < l; i++ {
-		f := sref.Field(i)
-		sf := stp.Field(i)
-		var name string
-		if gtag, ok := sf.Tag.Lookup("genji"); ok {
-			if gtag == "-" {
-				continue
-			}
+	fields, err := structFields(sref.Type())
+	if err != nil {
+		return err
+	}
 
-			name = gtag
-		} else {
-			name = strings.ToLower(sf.Name)
-		}
-		v, err := d.GetByField(name)
+	for _, sf := range fields {
+		v, err := d.GetByField(sf.name)
 		if err == ErrFieldNotFound {
+			// omitempty fields are absent when they hold their zero value
+			if sf.omitempty {
+				if f, ok := fieldByIndex(sref, sf.index); ok {
+					f.Set(reflect.Zero(f.Type()))
+				}
+			}
 			continue
 		}
 		if err != nil {
 			return err
 		}
 
-		if err := scanValue(v, f); err != nil {
+		if err := scanValue(v, allocFieldByIndex(sref, sf.index)); err != nil {
 			return err
 		}
 	}
@@ -93,6 +90,22 @@ func structScan(d Document, ref reflect.Value) error {
 	return nil
 }
 
+// allocFieldByIndex returns the field of the struct ref at the given index sequence,
+// allocating the nil embedded pointers found along the way.
+func allocFieldByIndex(ref reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && ref.Kind() == reflect.Ptr {
+			if ref.IsNil() {
+				ref.Set(reflect.New(ref.Type().Elem()))
+			}
+			ref = ref.Elem()
+		}
+		ref = ref.Field(x)
+	}
+
+	return ref
+}
+
 // SliceScan scans a document array into a slice or fixed size array. t must be a pointer
 // to a valid slice or array.
 //
@@ -153,11 +166,7 @@ func sliceScan(a Array, ref reflect.Value) error {
 				return err
 			}
 
-			if stp.Elem().Kind() == reflect.Ptr {
-				sref = reflect.Append(sref, newV)
-			} else {
-				sref = reflect.Append(sref, reflect.Indirect(newV))
-			}
+			sref = reflect.Append(sref, reflect.Indirect(newV))
 		}
 
 		return nil
@@ -218,11 +227,21 @@ func ScanValue(v Value, t interface{}) error {
 	return scanValue(v, reflect.ValueOf(t))
 }
 
+var timeType = reflect.TypeOf(time.Time{})
+var uuidType = reflect.TypeOf(UUID{})
+
 func scanValue(v Value, ref reflect.Value) error {
 	if !ref.IsValid() {
 		return &ErrUnsupportedType{ref, "parameter is not a valid reference"}
 	}
 
+	// pointers that are scanned into, e.g. struct fields, are set to nil
+	// when the value is null, so that an unset value can be told apart from a zero value
+	if ref.Kind() == reflect.Ptr && ref.CanSet() && v.Type == NullValue {
+		ref.Set(reflect.Zero(ref.Type()))
+		return nil
+	}
+
 	if ref.Type().Kind() == reflect.Ptr && ref.IsNil() {
 		ref.Set(reflect.New(ref.Type().Elem()))
 	}
@@ -234,6 +253,11 @@ func scanValue(v Value, ref reflect.Value) error {
 	// or create one
 	// then dereference
 	if ref.Kind() == reflect.Ptr {
+		if v.Type == NullValue {
+			ref.Set(reflect.Zero(ref.Type()))
+			return nil
+		}
+
 		if ref.IsNil() {
 			ref.Set(reflect.New(ref.Type().Elem()))
 		}
@@ -241,6 +265,24 @@ func scanValue(v Value, ref reflect.Value) error {
 		ref = reflect.Indirect(ref)
 	}
 
+	if ref.Type() == timeType {
+		x, err := v.ConvertToTime()
+		if err != nil {
+			return err
+		}
+		ref.Set(reflect.ValueOf(x))
+		return nil
+	}
+
+	if ref.Type() == uuidType {
+		x, err := v.ConvertToUUID()
+		if err != nil {
+			return err
+		}
+		ref.Set(reflect.ValueOf(UUID(x)))
+		return nil
+	}
+
 	switch ref.Kind() {
 	case reflect.String:
 		x, err := v.ConvertToText()
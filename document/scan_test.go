@@ -133,6 +133,69 @@ func TestScan(t *testing.T) {
 	})
 }
 
+func TestScanNullPointers(t *testing.T) {
+	type user struct {
+		Name *string
+		Age  *int64
+		Tags []*int
+	}
+
+	t.Run("StructScan", func(t *testing.T) {
+		name := "foo"
+		d, err := document.NewFromStruct(user{Name: &name})
+		require.NoError(t, err)
+
+		v, err := d.GetByField("age")
+		require.NoError(t, err)
+		require.Equal(t, document.NullValue, v.Type)
+
+		// pointers holding a value must be reset
+		age := int64(10)
+		u := user{Age: &age}
+		err = document.StructScan(d, &u)
+		require.NoError(t, err)
+		require.Equal(t, "foo", *u.Name)
+		require.Nil(t, u.Age)
+	})
+
+	t.Run("Zero values", func(t *testing.T) {
+		var name string
+		var age int64
+		d, err := document.NewFromStruct(user{Name: &name, Age: &age})
+		require.NoError(t, err)
+
+		var u user
+		err = document.StructScan(d, &u)
+		require.NoError(t, err)
+		require.NotNil(t, u.Name)
+		require.Equal(t, "", *u.Name)
+		require.NotNil(t, u.Age)
+		require.Equal(t, int64(0), *u.Age)
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		d := document.NewFieldBuffer().Add("tags", document.NewArrayValue(
+			document.NewValueBuffer(document.NewInt64Value(1), document.NewNullValue())))
+
+		var u user
+		err := document.StructScan(d, &u)
+		require.NoError(t, err)
+		require.Len(t, u.Tags, 2)
+		require.Equal(t, 1, *u.Tags[0])
+		require.Nil(t, u.Tags[1])
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		x := 10
+		p := &x
+		err := document.NewNullValue().Scan(&p)
+		require.NoError(t, err)
+		require.Nil(t, p)
+		// the value pointed to is left untouched
+		require.Equal(t, 10, x)
+	})
+}
+
 type documentScanner struct {
 	fn func(d document.Document) error
 }
@@ -2,10 +2,12 @@ package document
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"reflect"
 	"strconv"
 	"strings"
@@ -22,6 +24,8 @@ var (
 	int64ZeroValue    = NewZeroValue(Int64Value)
 	float64ZeroValue  = NewZeroValue(Float64Value)
 	durationZeroValue = NewZeroValue(DurationValue)
+	ipZeroValue       = NewZeroValue(IPValue)
+	uuidZeroValue     = NewZeroValue(UUIDValue)
 )
 
 // this error is used to skip struct or array fields that are not supported.
@@ -54,6 +58,12 @@ const (
 	ArrayValue
 
 	DurationValue
+
+	IPValue
+
+	UUIDValue
+
+	TimeValue
 )
 
 func (t ValueType) String() string {
@@ -82,9 +92,29 @@ func (t ValueType) String() string {
 		return "array"
 	case DurationValue:
 		return "duration"
+	case IPValue:
+		return "ip"
+	case UUIDValue:
+		return "uuid"
+	case TimeValue:
+		return "time"
+	}
+
+	return fmt.Sprintf("ValueType(%d)", uint8(t))
+}
+
+// ParseValueType returns the value type whose name is s, as returned by ValueType.String.
+// The comparison is case insensitive.
+func ParseValueType(s string) (ValueType, error) {
+	s = strings.ToLower(s)
+
+	for t := BlobValue; t <= TimeValue; t++ {
+		if t.String() == s {
+			return t, nil
+		}
 	}
 
-	return ""
+	return 0, fmt.Errorf("unknown value type %q", s)
 }
 
 // IsNumber returns true if t is either an integer of a float.
@@ -108,12 +138,21 @@ type Value struct {
 	V    interface{}
 }
 
-// NewValue creates a value whose type is infered from x.
+// NewValue creates a value whose type is inferred from x.
 func NewValue(x interface{}) (Value, error) {
 	// Attempt exact matches first:
 	switch v := x.(type) {
 	case time.Duration:
 		return NewDurationValue(v), nil
+	case net.IP:
+		if v == nil {
+			return NewNullValue(), nil
+		}
+		return NewIPValue(v)
+	case UUID:
+		return NewUUIDValue(v), nil
+	case time.Time:
+		return NewTimeValue(v), nil
 	case nil:
 		return NewNullValue(), nil
 	case Document:
@@ -270,6 +309,98 @@ func NewDurationValue(d time.Duration) Value {
 	}
 }
 
+// NewIPValue returns a value of type IP.
+// IPv4 addresses are stored in their 16-byte IPv4-mapped form, so that
+// IPv4 and IPv6 addresses can be compared and sorted together.
+// It returns an error if ip is neither a 4-byte nor a 16-byte address.
+func NewIPValue(ip net.IP) (Value, error) {
+	x := ip.To16()
+	if x == nil {
+		return Value{}, fmt.Errorf("invalid IP address of length %d", len(ip))
+	}
+
+	return Value{
+		Type: IPValue,
+		V:    x,
+	}, nil
+}
+
+// UUID designates a universally unique identifier.
+// NewValue, and therefore NewFromStruct, store values of this type as UUID values,
+// while other 16 bytes arrays are stored as arrays.
+type UUID [16]byte
+
+// NewUUIDValue returns a value of type UUID.
+// UUIDs are compared and sorted byte by byte, so that time-ordered UUIDs,
+// like version 7 UUIDs, are sorted by creation time.
+func NewUUIDValue(u [16]byte) Value {
+	return Value{
+		Type: UUIDValue,
+		V:    u,
+	}
+}
+
+// ParseUUIDValue parses a UUID in its canonical textual form,
+// e.g. 123e4567-e89b-12d3-a456-426614174000, and returns a value of type UUID.
+// The hexadecimal form without hyphens is also accepted.
+func ParseUUIDValue(s string) (Value, error) {
+	u, err := parseUUID(s)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return NewUUIDValue(u), nil
+}
+
+func parseUUID(s string) ([16]byte, error) {
+	var u [16]byte
+
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, fmt.Errorf("invalid uuid %q", s)
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+
+	if len(s) != 32 {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+
+	_, err := hex.Decode(u[:], []byte(s))
+	if err != nil {
+		return u, fmt.Errorf("invalid uuid %q", s)
+	}
+
+	return u, nil
+}
+
+func formatUUID(u [16]byte) string {
+	var buf [36]byte
+
+	hex.Encode(buf[:8], u[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+
+	return string(buf[:])
+}
+
+// NewTimeValue returns a value of type Time.
+// The time is converted to UTC and its monotonic clock reading is stripped,
+// so that times representing the same instant are equal.
+// Times are compared and sorted chronologically.
+func NewTimeValue(t time.Time) Value {
+	return Value{
+		Type: TimeValue,
+		V:    t.UTC().Round(0),
+	}
+}
+
 // NewArrayValue returns a value of type Array.
 func NewArrayValue(a Array) Value {
 	return Value{
@@ -317,6 +448,12 @@ func NewZeroValue(t ValueType) Value {
 		return NewArrayValue(NewValueBuffer())
 	case DurationValue:
 		return NewDurationValue(0)
+	case IPValue:
+		return Value{Type: IPValue, V: net.IPv6unspecified}
+	case UUIDValue:
+		return NewUUIDValue([16]byte{})
+	case TimeValue:
+		return NewTimeValue(time.Time{})
 	}
 
 	return Value{}
@@ -327,38 +464,76 @@ func (v Value) IsTruthy() bool {
 	return !v.IsZeroValue()
 }
 
-// String returns a string representation of the value. It implements the fmt.Stringer interface.
+// String returns a string representation of the value, meant for debugging and logging.
+// It implements the fmt.Stringer interface.
+// Texts are quoted, blobs are written in hexadecimal, documents and arrays in JSON,
+// and nulls as NULL. Other values are written in their natural textual form.
+// String never panics: if the value doesn't hold the data expected for its type,
+// the data is printed as is.
 func (v Value) String() string {
-	switch v.Type {
-	case DocumentValue:
-		var buf bytes.Buffer
-		err := ToJSON(&buf, v.V.(Document))
-		if err != nil {
-			panic(err)
+	switch x := v.V.(type) {
+	case []byte:
+		switch v.Type {
+		case TextValue:
+			return strconv.Quote(string(x))
+		case BlobValue:
+			return "0x" + hex.EncodeToString(x)
 		}
-		return buf.String()
-	case ArrayValue:
-		var buf bytes.Buffer
-		err := ArrayToJSON(&buf, v.V.(Array))
-		if err != nil {
-			panic(err)
+	case Document:
+		if v.Type == DocumentValue {
+			var buf bytes.Buffer
+			err := ToJSON(&buf, x)
+			if err != nil {
+				return fmt.Sprintf("<invalid document: %v>", err)
+			}
+			return strings.TrimSuffix(buf.String(), "\n")
 		}
-		return buf.String()
-	case NullValue:
+	case Array:
+		if v.Type == ArrayValue {
+			var buf bytes.Buffer
+			err := ArrayToJSON(&buf, x)
+			if err != nil {
+				return fmt.Sprintf("<invalid array: %v>", err)
+			}
+			return strings.TrimSuffix(buf.String(), "\n")
+		}
+	case [16]byte:
+		if v.Type == UUIDValue {
+			return formatUUID(x)
+		}
+	case time.Time:
+		if v.Type == TimeValue {
+			return x.Format(time.RFC3339Nano)
+		}
+	}
+
+	if v.Type == NullValue {
 		return "NULL"
-	case TextValue:
-		return string(v.V.([]byte))
 	}
 
 	return fmt.Sprintf("%v", v.V)
 }
 
 // ConvertTo decodes v to the selected type when possible.
+// Numbers can be converted to any other number type as long as the value fits
+// the target type without loss of precision. Texts are parsed when converted
+// to numbers, and numbers and booleans are formatted when converted to texts.
 func (v Value) ConvertTo(t ValueType) (Value, error) {
 	if v.Type == t {
 		return v, nil
 	}
 
+	switch {
+	case v.Type == TextValue && t.IsNumber() && t != DurationValue:
+		x, err := parseNumber(string(v.V.([]byte)))
+		if err != nil {
+			return Value{}, fmt.Errorf("can't convert %q to %q: %v", v.Type, t, err)
+		}
+		return x.ConvertTo(t)
+	case (v.Type.IsNumber() || v.Type == BoolValue) && t == TextValue:
+		return NewTextValue(formatNumber(v)), nil
+	}
+
 	switch t {
 	case BlobValue:
 		x, err := v.ConvertToBlob()
@@ -392,7 +567,7 @@ func (v Value) ConvertTo(t ValueType) (Value, error) {
 		if err != nil {
 			return Value{}, err
 		}
-		if x > math.MaxInt8 {
+		if x > math.MaxInt8 || x < math.MinInt8 {
 			return Value{}, fmt.Errorf("cannot convert %s to int8: out of range", v.Type)
 		}
 
@@ -405,7 +580,7 @@ func (v Value) ConvertTo(t ValueType) (Value, error) {
 		if err != nil {
 			return Value{}, err
 		}
-		if x > math.MaxInt16 {
+		if x > math.MaxInt16 || x < math.MinInt16 {
 			return Value{}, fmt.Errorf("cannot convert %s to int16: out of range", v.Type)
 		}
 		return Value{
@@ -417,7 +592,7 @@ func (v Value) ConvertTo(t ValueType) (Value, error) {
 		if err != nil {
 			return Value{}, err
 		}
-		if x > math.MaxInt32 {
+		if x > math.MaxInt32 || x < math.MinInt32 {
 			return Value{}, fmt.Errorf("cannot convert %s to int32: out of range", v.Type)
 		}
 		return Value{
@@ -451,6 +626,27 @@ func (v Value) ConvertTo(t ValueType) (Value, error) {
 			Type: DurationValue,
 			V:    x,
 		}, nil
+	case IPValue:
+		x, err := v.ConvertToIP()
+		if err != nil {
+			return Value{}, err
+		}
+		if x == nil {
+			return NewZeroValue(IPValue), nil
+		}
+		return NewIPValue(x)
+	case UUIDValue:
+		x, err := v.ConvertToUUID()
+		if err != nil {
+			return Value{}, err
+		}
+		return NewUUIDValue(x), nil
+	case TimeValue:
+		x, err := v.ConvertToTime()
+		if err != nil {
+			return Value{}, err
+		}
+		return NewTimeValue(x), nil
 	}
 
 	return Value{}, fmt.Errorf("can't convert %q to %q", v.Type, t)
@@ -462,6 +658,11 @@ func (v Value) ConvertToBlob() ([]byte, error) {
 	switch v.Type {
 	case TextValue, BlobValue:
 		return v.V.([]byte), nil
+	case IPValue:
+		return []byte(v.V.(net.IP)), nil
+	case UUIDValue:
+		u := v.V.([16]byte)
+		return u[:], nil
 	}
 
 	if v.Type == NullValue {
@@ -477,6 +678,12 @@ func (v Value) ConvertToText() (string, error) {
 	switch v.Type {
 	case TextValue, BlobValue:
 		return string(v.V.([]byte)), nil
+	case IPValue:
+		return v.V.(net.IP).String(), nil
+	case UUIDValue:
+		return formatUUID(v.V.([16]byte)), nil
+	case TimeValue:
+		return v.V.(time.Time).Format(time.RFC3339Nano), nil
 	}
 
 	if v.Type == NullValue {
@@ -606,6 +813,80 @@ func (v Value) ConvertToDuration() (time.Duration, error) {
 	return time.Duration(x), err
 }
 
+// ConvertToIP returns an IP address from the value.
+// It works with IP values, texts representing an IPv4 or IPv6 address
+// and blobs of 4 or 16 bytes.
+func (v Value) ConvertToIP() (net.IP, error) {
+	switch v.Type {
+	case IPValue:
+		return v.V.(net.IP), nil
+	case NullValue:
+		return nil, nil
+	case TextValue:
+		ip := net.ParseIP(string(v.V.([]byte)))
+		if ip == nil {
+			return nil, fmt.Errorf("can't convert %q to ip: invalid address", v.V)
+		}
+		return ip, nil
+	case BlobValue:
+		b := v.V.([]byte)
+		if len(b) != net.IPv4len && len(b) != net.IPv6len {
+			return nil, fmt.Errorf("can't convert blob of length %d to ip", len(b))
+		}
+		return net.IP(b), nil
+	}
+
+	return nil, fmt.Errorf("can't convert %q to ip", v.Type)
+}
+
+// ConvertToUUID returns a UUID from the value.
+// It works with UUID values, texts representing a UUID
+// and blobs of 16 bytes.
+func (v Value) ConvertToUUID() ([16]byte, error) {
+	switch v.Type {
+	case UUIDValue:
+		return v.V.([16]byte), nil
+	case NullValue:
+		return [16]byte{}, nil
+	case TextValue:
+		u, err := parseUUID(string(v.V.([]byte)))
+		if err != nil {
+			return u, fmt.Errorf("can't convert %q to uuid: %v", v.V, err)
+		}
+		return u, nil
+	case BlobValue:
+		var u [16]byte
+		b := v.V.([]byte)
+		if len(b) != len(u) {
+			return u, fmt.Errorf("can't convert blob of length %d to uuid", len(b))
+		}
+		copy(u[:], b)
+		return u, nil
+	}
+
+	return [16]byte{}, fmt.Errorf("can't convert %q to uuid", v.Type)
+}
+
+// ConvertToTime returns a time from the value.
+// It works with time values and texts representing a time in the RFC 3339 format,
+// e.g. 2006-01-02T15:04:05Z or 2006-01-02T15:04:05.999999999+07:00.
+func (v Value) ConvertToTime() (time.Time, error) {
+	switch v.Type {
+	case TimeValue:
+		return v.V.(time.Time), nil
+	case NullValue:
+		return time.Time{}, nil
+	case TextValue:
+		t, err := time.Parse(time.RFC3339Nano, string(v.V.([]byte)))
+		if err != nil {
+			return t, fmt.Errorf("can't convert %q to time: %v", v.V, err)
+		}
+		return t.UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("can't convert %q to time", v.Type)
+}
+
 // IsZeroValue indicates if the value data is the zero value for the value type.
 // This function doesn't perform any allocation.
 func (v Value) IsZeroValue() bool {
@@ -626,13 +907,116 @@ func (v Value) IsZeroValue() bool {
 		return v.V == float64ZeroValue.V
 	case DurationValue:
 		return v.V == durationZeroValue.V
+	case IPValue:
+		return bytes.Equal(v.V.(net.IP), ipZeroValue.V.(net.IP))
+	case UUIDValue:
+		return v.V == uuidZeroValue.V
+	case TimeValue:
+		return v.V.(time.Time).IsZero()
 	}
 
 	return false
 }
 
+// IsNull returns true if v is of type Null.
+// Unlike IsZeroValue, the zero value of other types is never null.
+func (v Value) IsNull() bool {
+	return v.Type == NullValue
+}
+
+// Canonicalize returns the canonical representation of v, so that values that are
+// logically equal but were built differently are stored and compared identically.
+// The rules are the following:
+//
+//   - integers are converted to the smallest integer type that can hold them
+//   - floats with no fractional part that fit in an int64 are converted to the smallest
+//     integer type that can hold them, -0 becoming 0. NaN and infinities are left untouched
+//   - IPv4 addresses are converted to their 16-byte IPv4-mapped form
+//   - values of documents and arrays are canonicalized when they are read
+//   - other values are returned unchanged
+func (v Value) Canonicalize() Value {
+	switch v.Type {
+	case Int8Value, Int16Value, Int32Value, Int64Value:
+		x, _ := v.ConvertToInt64()
+		return intToValue(x)
+	case Float64Value:
+		x := v.V.(float64)
+		if x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64 {
+			return intToValue(int64(x))
+		}
+	case IPValue:
+		return Value{Type: IPValue, V: v.V.(net.IP).To16()}
+	case DocumentValue:
+		if _, ok := v.V.(canonicalDocument); !ok {
+			return NewDocumentValue(canonicalDocument{v.V.(Document)})
+		}
+	case ArrayValue:
+		if _, ok := v.V.(canonicalArray); !ok {
+			return NewArrayValue(canonicalArray{v.V.(Array)})
+		}
+	}
+
+	return v
+}
+
+// canonicalDocument canonicalizes the values of the underlying document when they are read.
+type canonicalDocument struct {
+	Document
+}
+
+func (c canonicalDocument) Iterate(fn func(f string, v Value) error) error {
+	return c.Document.Iterate(func(f string, v Value) error {
+		return fn(f, v.Canonicalize())
+	})
+}
+
+func (c canonicalDocument) GetByField(field string) (Value, error) {
+	v, err := c.Document.GetByField(field)
+	if err != nil {
+		return v, err
+	}
+
+	return v.Canonicalize(), nil
+}
+
+// canonicalArray canonicalizes the values of the underlying array when they are read.
+type canonicalArray struct {
+	Array
+}
+
+func (c canonicalArray) Iterate(fn func(i int, v Value) error) error {
+	return c.Array.Iterate(func(i int, v Value) error {
+		return fn(i, v.Canonicalize())
+	})
+}
+
+func (c canonicalArray) GetByIndex(i int) (Value, error) {
+	v, err := c.Array.GetByIndex(i)
+	if err != nil {
+		return v, err
+	}
+
+	return v.Canonicalize(), nil
+}
+
 // MarshalJSON implements the json.Marshaler interface.
+// Values are encoded so that UnmarshalJSON decodes them back to the same type.
+// Nulls, booleans, texts, documents and arrays are encoded as their JSON counterparts,
+// integers as JSON numbers if their type is the smallest one that can hold them and
+// floats as JSON numbers containing a decimal point or an exponent.
+// Any other value is encoded as a JSON object with a single field named after its type,
+// prefixed by a dollar sign, e.g. {"$int64": 10}, {"$blob": "AAH/"}, {"$duration": "1.5s"},
+// {"$ip": "10.0.0.1"}, {"$uuid": "..."}, {"$time": "2006-01-02T15:04:05Z"} or {"$float64": "NaN"}.
+// Documents that would be mistaken for such an object are wrapped in a {"$document": ...} object.
 func (v Value) MarshalJSON() ([]byte, error) {
+	return v.marshalJSON(true)
+}
+
+// marshalJSON encodes v to JSON. If typed is false, values are encoded
+// using their closest JSON representation, which loses their type:
+// blobs are encoded as base64 strings, UUIDs, times and IPs as strings,
+// and durations as numbers of nanoseconds.
+func (v Value) marshalJSON(typed bool) ([]byte, error) {
 	var x interface{}
 
 	switch v.Type {
@@ -641,24 +1025,90 @@ func (v Value) MarshalJSON() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		x = &jsonDocument{d}
+		x = &jsonDocument{Document: d, typed: typed}
+		if typed {
+			_, _, ok, err := jsonTypeField(d)
+			if err != nil {
+				return nil, err
+			}
+			// prevent the document from being decoded as a value of another type
+			if ok {
+				x = map[string]interface{}{"$" + DocumentValue.String(): x}
+			}
+		}
 	case ArrayValue:
 		a, err := v.ConvertToArray()
 		if err != nil {
 			return nil, err
 		}
-		x = &jsonArray{a}
-	case TextValue, BlobValue:
-		s, err := v.ConvertToText()
+		x = &jsonArray{Array: a, typed: typed}
+	case TextValue:
+		x = string(v.V.([]byte))
+	case UUIDValue:
+		x = formatUUID(v.V.([16]byte))
+	case TimeValue:
+		x = v.V.(time.Time).Format(time.RFC3339Nano)
+	default:
+		x = v.V
+	}
+
+	if !typed {
+		return json.Marshal(x)
+	}
+
+	switch v.Type {
+	case Int8Value, Int16Value, Int32Value, Int64Value:
+		i, err := v.ConvertToInt64()
+		if err != nil {
+			return nil, err
+		}
+		// JSON integers are decoded to the smallest integer type that can hold them
+		if intToValue(i).Type == v.Type {
+			return json.Marshal(x)
+		}
+	case Float64Value:
+		f := v.V.(float64)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			x = formatNumber(v)
+			break
+		}
+		data, err := json.Marshal(f)
 		if err != nil {
 			return nil, err
 		}
-		x = s
+		// ensure the number isn't decoded as an integer
+		if !bytes.ContainsAny(data, ".eE") {
+			data = append(data, ".0"...)
+		}
+		return data, nil
+	case DurationValue:
+		x = formatNumber(v)
+	case BlobValue, IPValue, UUIDValue, TimeValue:
 	default:
-		x = v.V
+		return json.Marshal(x)
+	}
+
+	return json.Marshal(map[string]interface{}{"$" + v.Type.String(): x})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It decodes values encoded by MarshalJSON to their original type.
+// The type of other JSON values is inferred: strings become texts,
+// integers the smallest integer type that can hold them, other numbers floats,
+// objects documents and arrays arrays.
+func (v *Value) UnmarshalJSON(data []byte) error {
+	x, err := parseJSONValue(json.NewDecoder(bytes.NewReader(data)))
+	if err != nil {
+		return err
+	}
+
+	x, err = decodeTypedJSON(x)
+	if err != nil {
+		return err
 	}
 
-	return json.Marshal(x)
+	*v = x
+	return nil
 }
 
 // Scan v into t.
@@ -745,12 +1195,14 @@ func (v Value) Compare(u Value) int {
 		if un {
 			vf, _ = v.ConvertToFloat64()
 		} else {
-			vf, _ = strconv.ParseFloat(v.String(), 64)
+			s, _ := v.ConvertToText()
+			vf, _ = strconv.ParseFloat(s, 64)
 		}
 		if vn {
 			uf, _ = u.ConvertToFloat64()
 		} else {
-			uf, _ = strconv.ParseFloat(u.String(), 64)
+			s, _ := u.ConvertToText()
+			uf, _ = strconv.ParseFloat(s, 64)
 		}
 		return int(vf - uf)
 	}
@@ -763,7 +1215,18 @@ func (v Value) Compare(u Value) int {
 	}
 
 	// if all else fails, compare string representation of values
-	return strings.Compare(v.String(), u.String())
+	return bytes.Compare(v.compareBytes(), u.compareBytes())
+}
+
+// compareBytes returns the representation of v used by Compare when the values
+// can't be compared otherwise. Texts and blobs are represented by their raw content,
+// other values by their String representation.
+func (v Value) compareBytes() []byte {
+	if b, ok := v.V.([]byte); ok && (v.Type == TextValue || v.Type == BlobValue) {
+		return b
+	}
+
+	return []byte(v.String())
 }
 
 func calculateValues(a, b Value, operator byte) (res Value, err error) {
@@ -809,6 +1272,39 @@ func calculateValues(a, b Value, operator byte) (res Value, err error) {
 	return
 }
 
+// parseNumber parses s as an int64 if possible, as a float64 otherwise.
+func parseNumber(s string) (Value, error) {
+	s = strings.TrimSpace(s)
+
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return NewInt64Value(i), nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("%q is not a number", s)
+	}
+
+	return NewFloat64Value(f), nil
+}
+
+// formatNumber returns the text representation of a number or a boolean.
+// Durations are formatted so that they can be parsed back with time.ParseDuration.
+func formatNumber(v Value) string {
+	switch v.Type {
+	case BoolValue:
+		return strconv.FormatBool(v.V.(bool))
+	case Float64Value:
+		return strconv.FormatFloat(v.V.(float64), 'g', -1, 64)
+	case DurationValue:
+		return v.V.(time.Duration).String()
+	}
+
+	i, _ := convertNumberToInt64(v)
+	return strconv.FormatInt(i, 10)
+}
+
 func convertNumberToInt64(v Value) (int64, error) {
 	var i int64
 
@@ -823,7 +1319,7 @@ func convertNumberToInt64(v Value) (int64, error) {
 		return v.V.(int64), nil
 	case Float64Value:
 		f := v.V.(float64)
-		if f > math.MaxInt64 {
+		if f >= math.MaxInt64 || f < math.MinInt64 {
 			return i, errors.New("cannot convert float64 to integer without overflowing")
 		}
 		if math.Trunc(f) != f {
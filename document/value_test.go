@@ -1,8 +1,12 @@
 package document_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"math"
+	"net"
 	"testing"
 	"time"
 
@@ -16,8 +20,10 @@ func TestValueString(t *testing.T) {
 		value    document.Value
 		expected string
 	}{
-		{"bytes", document.NewBlobValue([]byte("bar")), "[98 97 114]"},
-		{"string", document.NewTextValue("bar"), "bar"},
+		{"bytes", document.NewBlobValue([]byte("bar")), "0x626172"},
+		{"empty bytes", document.NewBlobValue(nil), "0x"},
+		{"string", document.NewTextValue("bar"), `"bar"`},
+		{"string with quotes", document.NewTextValue("\"a\"\n"), `"\"a\"\n"`},
 		{"bool", document.NewBoolValue(true), "true"},
 		{"int", document.NewIntValue(10), "10"},
 		{"int8", document.NewInt8Value(10), "10"},
@@ -25,9 +31,14 @@ func TestValueString(t *testing.T) {
 		{"int32", document.NewInt32Value(10), "10"},
 		{"int64", document.NewInt64Value(10), "10"},
 		{"float64", document.NewFloat64Value(10.1), "10.1"},
-		{"document", document.NewDocumentValue(document.NewFieldBuffer().Add("a", document.NewIntValue(10))), "{\"a\":10}\n"},
-		{"array", document.NewArrayValue(document.NewValueBuffer(document.NewIntValue(10))), "[10]\n"},
+		{"document", document.NewDocumentValue(document.NewFieldBuffer().Add("a", document.NewIntValue(10))), "{\"a\":10}"},
+		{"array", document.NewArrayValue(document.NewValueBuffer(document.NewIntValue(10))), "[10]"},
 		{"duration", document.NewDurationValue(10 * time.Nanosecond), "10ns"},
+		{"null", document.NewNullValue(), "NULL"},
+		{"ip", mustIPValue(net.IPv4(10, 0, 0, 1)), "10.0.0.1"},
+		{"malformed text", document.Value{Type: document.TextValue, V: 10}, "10"},
+		{"malformed document", document.Value{Type: document.DocumentValue, V: "foo"}, "foo"},
+		{"malformed blob", document.Value{Type: document.BlobValue}, "<nil>"},
 	}
 
 	for _, test := range tests {
@@ -37,6 +48,32 @@ func TestValueString(t *testing.T) {
 	}
 }
 
+func TestValueTypeString(t *testing.T) {
+	require.Equal(t, "int64", document.Int64Value.String())
+	require.Equal(t, "ip", document.IPValue.String())
+	require.Equal(t, "uuid", document.UUIDValue.String())
+	require.Equal(t, "time", document.TimeValue.String())
+	require.Equal(t, "ValueType(0)", document.ValueType(0).String())
+	require.Equal(t, "ValueType(200)", document.ValueType(200).String())
+}
+
+func TestParseValueType(t *testing.T) {
+	for tp := document.BlobValue; tp <= document.TimeValue; tp++ {
+		got, err := document.ParseValueType(tp.String())
+		require.NoError(t, err)
+		require.Equal(t, tp, got)
+	}
+
+	got, err := document.ParseValueType("TEXT")
+	require.NoError(t, err)
+	require.Equal(t, document.TextValue, got)
+
+	_, err = document.ParseValueType("foo")
+	require.Error(t, err)
+	_, err = document.ParseValueType("ValueType(0)")
+	require.Error(t, err)
+}
+
 func TestNewValue(t *testing.T) {
 	type st struct {
 		A int
@@ -78,6 +115,8 @@ func TestNewValue(t *testing.T) {
 		{"document", document.NewFieldBuffer().Add("a", document.NewIntValue(10)), document.NewFieldBuffer().Add("a", document.NewIntValue(10))},
 		{"array", document.NewValueBuffer(document.NewIntValue(10)), document.NewValueBuffer(document.NewIntValue(10))},
 		{"duration", 10 * time.Nanosecond, 10 * time.Nanosecond},
+		{"ipv4", net.IP{10, 0, 0, 1}, net.IPv4(10, 0, 0, 1)},
+		{"ipv6", net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1")},
 		{"bytes", myBytes("bar"), []byte("bar")},
 		{"string", myString("bar"), []byte("bar")},
 		{"myUint", myUint(10), int8(10)},
@@ -286,6 +325,75 @@ func TestConvertToNumber(t *testing.T) {
 	})
 }
 
+func TestValueConvertTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        document.Value
+		t        document.ValueType
+		fails    bool
+		expected document.Value
+	}{
+		{"same type", document.NewInt16Value(10), document.Int16Value, false, document.NewInt16Value(10)},
+		{"int8 to int64", document.NewInt8Value(-10), document.Int64Value, false, document.NewInt64Value(-10)},
+		{"int64 to int8", document.NewInt64Value(-10), document.Int8Value, false, document.NewInt8Value(-10)},
+		{"int64 to int8/overflow", document.NewInt64Value(-1000), document.Int8Value, true, document.Value{}},
+		{"int32 to int16/overflow", document.NewInt32Value(math.MinInt32), document.Int16Value, true, document.Value{}},
+		{"int64 to float64", document.NewInt64Value(10), document.Float64Value, false, document.NewFloat64Value(10)},
+		{"float64 to int64", document.NewFloat64Value(-10), document.Int64Value, false, document.NewInt64Value(-10)},
+		{"float64 to int64/overflow", document.NewFloat64Value(-1e19), document.Int64Value, true, document.Value{}},
+		{"float64 to int64/max", document.NewFloat64Value(math.MaxInt64), document.Int64Value, true, document.Value{}},
+		{"bool to int32", document.NewBoolValue(true), document.Int32Value, false, document.NewInt32Value(1)},
+		{"text to int64", document.NewTextValue("42"), document.Int64Value, false, document.NewInt64Value(42)},
+		{"text to int8", document.NewTextValue(" -42 "), document.Int8Value, false, document.NewInt8Value(-42)},
+		{"text to int8/overflow", document.NewTextValue("420"), document.Int8Value, true, document.Value{}},
+		{"text to int64/whole float", document.NewTextValue("1e3"), document.Int64Value, false, document.NewInt64Value(1000)},
+		{"text to int64/float", document.NewTextValue("4.2"), document.Int64Value, true, document.Value{}},
+		{"text to float64", document.NewTextValue("4.2"), document.Float64Value, false, document.NewFloat64Value(4.2)},
+		{"text to float64/int", document.NewTextValue("42"), document.Float64Value, false, document.NewFloat64Value(42)},
+		{"text to float64/invalid", document.NewTextValue("foo"), document.Float64Value, true, document.Value{}},
+		{"text to duration", document.NewTextValue("10s"), document.DurationValue, false, document.NewDurationValue(10 * time.Second)},
+		{"int8 to text", document.NewInt8Value(-42), document.TextValue, false, document.NewTextValue("-42")},
+		{"int64 to text", document.NewInt64Value(math.MaxInt64), document.TextValue, false, document.NewTextValue("9223372036854775807")},
+		{"float64 to text", document.NewFloat64Value(4.2), document.TextValue, false, document.NewTextValue("4.2")},
+		{"duration to text", document.NewDurationValue(10 * time.Second), document.TextValue, false, document.NewTextValue("10s")},
+		{"bool to text", document.NewBoolValue(false), document.TextValue, false, document.NewTextValue("false")},
+		{"document to int64", document.NewDocumentValue(document.NewFieldBuffer()), document.Int64Value, true, document.Value{}},
+		{"array to text", document.NewArrayValue(document.NewValueBuffer()), document.TextValue, true, document.Value{}},
+		{"int64 to document", document.NewInt64Value(10), document.DocumentValue, true, document.Value{}},
+		{"text to ip", document.NewTextValue("10.0.0.1"), document.IPValue, false, mustIPValue(net.IPv4(10, 0, 0, 1))},
+		{"blob to ip/bad length", document.NewBlobValue([]byte{10, 0, 0}), document.IPValue, true, document.Value{}},
+		{"null to ip", document.NewNullValue(), document.IPValue, false, mustIPValue(net.IPv6unspecified)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.v.ConvertTo(test.t)
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+
+	t.Run("text round trip", func(t *testing.T) {
+		for _, v := range []document.Value{
+			document.NewInt64Value(math.MinInt64),
+			document.NewFloat64Value(1e20),
+			document.NewFloat64Value(0.1),
+			document.NewDurationValue(1500 * time.Millisecond),
+		} {
+			txt, err := v.ConvertTo(document.TextValue)
+			require.NoError(t, err)
+			res, err := txt.ConvertTo(v.Type)
+			require.NoError(t, err)
+			require.Equal(t, v, res)
+		}
+	})
+}
+
 func TestConvertToDuration(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -321,6 +429,67 @@ func TestConvertToDuration(t *testing.T) {
 	}
 }
 
+func TestNewIPValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		ip       net.IP
+		fails    bool
+		expected net.IP
+	}{
+		{"ipv4", net.IP{10, 0, 0, 1}, false, net.IPv4(10, 0, 0, 1)},
+		{"ipv4-mapped", net.IPv4(10, 0, 0, 1), false, net.IPv4(10, 0, 0, 1)},
+		{"ipv6", net.ParseIP("2001:db8::1"), false, net.ParseIP("2001:db8::1")},
+		{"nil", nil, true, nil},
+		{"empty", net.IP{}, true, nil},
+		{"bad length", net.IP{10, 0, 0}, true, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := document.NewIPValue(test.ip)
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, document.IPValue, v.Type)
+			require.Equal(t, test.expected, v.V)
+		})
+	}
+}
+
+func TestConvertToIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        document.Value
+		fails    bool
+		expected net.IP
+	}{
+		{"ipv4", mustIPValue(net.IPv4(10, 0, 0, 1)), false, net.ParseIP("10.0.0.1")},
+		{"ipv6", mustIPValue(net.ParseIP("2001:db8::1")), false, net.ParseIP("2001:db8::1")},
+		{"string", document.NewTextValue("10.0.0.1"), false, net.ParseIP("10.0.0.1")},
+		{"bad string", document.NewTextValue("foo"), true, nil},
+		{"bytes", document.NewBlobValue([]byte{10, 0, 0, 1}), false, net.IP{10, 0, 0, 1}},
+		{"bad bytes", document.NewBlobValue([]byte("bar")), true, nil},
+		{"bool", document.NewBoolValue(true), true, nil},
+		{"int", document.NewIntValue(10), true, nil},
+		{"null", document.NewNullValue(), false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.v.ConvertToIP()
+			if test.fails {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.True(t, test.expected.Equal(res))
+			}
+		})
+	}
+}
+
 func TestConvertToDocument(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -732,6 +901,19 @@ func TestValueCompare(t *testing.T) {
 	// generate another batch of tests mixing everything with everything
 	cartesian(texts, blobs)
 
+	// texts and blobs are compared to other types using their raw content,
+	// not their quoted or hexadecimal representation.
+	doc := document.NewDocumentValue(document.NewFieldBuffer().Add("a", document.NewInt64Value(1)))
+	id := document.NewUUIDValue([16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00})
+	tests = append(tests,
+		CompareTest{"text(|)>document", document.NewTextValue("|"), doc, 1},
+		CompareTest{"text(a)>uuid", document.NewTextValue("a"), id, 1},
+		CompareTest{"text(0)<uuid", document.NewTextValue("0"), id, -1},
+		CompareTest{"blob(ff)>document", document.NewBlobValue([]byte{0xff}), doc, 1},
+		CompareTest{"blob(a)>uuid", document.NewBlobValue([]byte("a")), id, 1},
+		CompareTest{"document<text(|)", doc, document.NewTextValue("|"), -1},
+	)
+
 	// Run the tests
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -740,3 +922,325 @@ func TestValueCompare(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertToUUID(t *testing.T) {
+	u := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+
+	tests := []struct {
+		name     string
+		v        document.Value
+		fails    bool
+		expected [16]byte
+	}{
+		{"uuid", document.NewUUIDValue(u), false, u},
+		{"string", document.NewTextValue("123e4567-e89b-12d3-a456-426614174000"), false, u},
+		{"string without hyphens", document.NewTextValue("123e4567e89b12d3a456426614174000"), false, u},
+		{"bad string", document.NewTextValue("123e4567-e89b-12d3-a456-42661417400z"), true, [16]byte{}},
+		{"misplaced hyphens", document.NewTextValue("123e4567e-89b-12d3-a456-426614174000"), true, [16]byte{}},
+		{"bytes", document.NewBlobValue(u[:]), false, u},
+		{"bad bytes", document.NewBlobValue([]byte("bar")), true, [16]byte{}},
+		{"int", document.NewIntValue(10), true, [16]byte{}},
+		{"null", document.NewNullValue(), false, [16]byte{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.v.ConvertToUUID()
+			if test.fails {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+
+	t.Run("NewValue", func(t *testing.T) {
+		v, err := document.NewValue(document.UUID(u))
+		require.NoError(t, err)
+		require.Equal(t, document.NewUUIDValue(u), v)
+
+		// other 16 bytes arrays, like hashes, are not UUIDs
+		v, err = document.NewValue(md5.Sum([]byte("foo")))
+		require.NoError(t, err)
+		require.Equal(t, document.ArrayValue, v.Type)
+
+		var res document.UUID
+		err = document.NewUUIDValue(u).Scan(&res)
+		require.NoError(t, err)
+		require.Equal(t, document.UUID(u), res)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		v, err := document.NewValue(document.UUID(u))
+		require.NoError(t, err)
+		require.Equal(t, document.UUIDValue, v.Type)
+		require.Equal(t, "123e4567-e89b-12d3-a456-426614174000", v.String())
+
+		data, err := v.MarshalJSON()
+		require.NoError(t, err)
+		require.Equal(t, `{"$uuid":"123e4567-e89b-12d3-a456-426614174000"}`, string(data))
+	})
+}
+
+func TestConvertToTime(t *testing.T) {
+	tm := time.Date(2020, 3, 14, 15, 9, 26, 535897932, time.UTC)
+
+	tests := []struct {
+		name     string
+		v        document.Value
+		fails    bool
+		expected time.Time
+	}{
+		{"time", document.NewTimeValue(tm), false, tm},
+		{"string", document.NewTextValue("2020-03-14T15:09:26.535897932Z"), false, tm},
+		{"string with offset", document.NewTextValue("2020-03-14T16:09:26.535897932+01:00"), false, tm},
+		{"bad string", document.NewTextValue("2020-03-14"), true, time.Time{}},
+		{"int", document.NewIntValue(10), true, time.Time{}},
+		{"null", document.NewNullValue(), false, time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.v.ConvertToTime()
+			if test.fails {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, res)
+			}
+		})
+	}
+
+	t.Run("text", func(t *testing.T) {
+		v, err := document.NewValue(tm.In(time.FixedZone("", 3600)))
+		require.NoError(t, err)
+		require.Equal(t, document.TimeValue, v.Type)
+		require.Equal(t, "2020-03-14T15:09:26.535897932Z", v.String())
+		require.Equal(t, document.NewTimeValue(tm), v)
+
+		data, err := v.MarshalJSON()
+		require.NoError(t, err)
+		require.Equal(t, `{"$time":"2020-03-14T15:09:26.535897932Z"}`, string(data))
+	})
+
+	t.Run("zero value", func(t *testing.T) {
+		require.True(t, document.NewZeroValue(document.TimeValue).IsZeroValue())
+		require.False(t, document.NewTimeValue(tm).IsZeroValue())
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		var got time.Time
+		err := document.NewTimeValue(tm).Scan(&got)
+		require.NoError(t, err)
+		require.Equal(t, tm, got)
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		type event struct {
+			At time.Time
+		}
+
+		d, err := document.NewFromStruct(event{At: tm})
+		require.NoError(t, err)
+		v, err := d.GetByField("at")
+		require.NoError(t, err)
+		require.Equal(t, document.NewTimeValue(tm), v)
+
+		var e event
+		err = document.StructScan(d, &e)
+		require.NoError(t, err)
+		require.Equal(t, tm, e.At)
+	})
+}
+
+func TestValueCanonicalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        document.Value
+		expected document.Value
+	}{
+		{"int64", document.NewInt64Value(10), document.NewInt8Value(10)},
+		{"int32", document.NewInt32Value(1000), document.NewInt16Value(1000)},
+		{"whole float", document.NewFloat64Value(10), document.NewInt8Value(10)},
+		{"big whole float", document.NewFloat64Value(1 << 40), document.NewInt64Value(1 << 40)},
+		{"negative zero", document.NewFloat64Value(math.Copysign(0, -1)), document.NewInt8Value(0)},
+		{"float", document.NewFloat64Value(10.5), document.NewFloat64Value(10.5)},
+		{"too big float", document.NewFloat64Value(1e20), document.NewFloat64Value(1e20)},
+		{"infinity", document.NewFloat64Value(math.Inf(1)), document.NewFloat64Value(math.Inf(1))},
+		{"ipv4", document.Value{Type: document.IPValue, V: net.IPv4(10, 0, 0, 1).To4()}, mustIPValue(net.IPv4(10, 0, 0, 1))},
+		{"text", document.NewTextValue("foo"), document.NewTextValue("foo")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.True(t, test.v.Canonicalize().StrictEqual(test.expected))
+		})
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		v := document.NewFloat64Value(math.NaN()).Canonicalize()
+		require.Equal(t, document.Float64Value, v.Type)
+		require.True(t, math.IsNaN(v.V.(float64)))
+	})
+
+	t.Run("document", func(t *testing.T) {
+		v := document.NewDocumentValue(document.NewFieldBuffer().
+			Add("a", document.NewFloat64Value(1)).
+			Add("b", document.NewArrayValue(document.NewValueBuffer(document.NewInt64Value(2))))).Canonicalize()
+
+		expected := document.NewDocumentValue(document.NewFieldBuffer().
+			Add("a", document.NewInt8Value(1)).
+			Add("b", document.NewArrayValue(document.NewValueBuffer(document.NewInt8Value(2)))))
+		require.True(t, v.StrictEqual(expected))
+
+		d, err := v.ConvertToDocument()
+		require.NoError(t, err)
+		a, err := d.GetByField("a")
+		require.NoError(t, err)
+		require.Equal(t, document.NewInt8Value(1), a)
+	})
+}
+
+func TestValueJSON(t *testing.T) {
+	tm := time.Date(2020, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	u := document.UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+
+	tests := []struct {
+		name     string
+		v        document.Value
+		expected string
+	}{
+		{"null", document.NewNullValue(), `null`},
+		{"bool", document.NewBoolValue(true), `true`},
+		{"int8", document.NewInt8Value(-10), `-10`},
+		{"int16", document.NewInt16Value(1000), `1000`},
+		{"int32", document.NewInt32Value(100000), `100000`},
+		{"int64", document.NewInt64Value(1 << 40), `1099511627776`},
+		{"small int16", document.NewInt16Value(10), `{"$int16": 10}`},
+		{"small int64", document.NewInt64Value(10), `{"$int64": 10}`},
+		{"float64", document.NewFloat64Value(10.5), `10.5`},
+		{"whole float64", document.NewFloat64Value(10), `10.0`},
+		{"large float64", document.NewFloat64Value(1e300), `1e+300`},
+		{"NaN", document.NewFloat64Value(math.NaN()), `{"$float64": "NaN"}`},
+		{"+Inf", document.NewFloat64Value(math.Inf(1)), `{"$float64": "+Inf"}`},
+		{"text", document.NewTextValue("foo \"bar\""), `"foo \"bar\""`},
+		{"blob", document.NewBlobValue([]byte{0, 1, 0xff}), `{"$blob": "AAH/"}`},
+		{"duration", document.NewDurationValue(1500 * time.Millisecond), `{"$duration": "1.5s"}`},
+		{"ipv4", mustIPValue(net.ParseIP("10.0.0.1")), `{"$ip": "10.0.0.1"}`},
+		{"ipv6", mustIPValue(net.ParseIP("::1")), `{"$ip": "::1"}`},
+		{"uuid", document.NewUUIDValue(u), `{"$uuid": "123e4567-e89b-12d3-a456-426614174000"}`},
+		{"time", document.NewTimeValue(tm), `{"$time": "2020-03-14T15:09:26.535897932Z"}`},
+		{"array", document.NewArrayValue(document.NewValueBuffer(document.NewInt8Value(1), document.NewTextValue("a"), document.NewBlobValue([]byte("a")))), `[1,"a",{"$blob": "YQ=="}]`},
+		{"document", document.NewDocumentValue(document.NewFieldBuffer().Add("a", document.NewInt8Value(1)).Add("b", document.NewNullValue()).Add("c", document.NewInt64Value(1))), `{"a": 1, "b": null, "c": {"$int64": 1}}`},
+		{"typed document", document.NewDocumentValue(document.NewFieldBuffer().Add("$blob", document.NewTextValue("AAH/"))), `{"$document": {"$blob": "AAH/"}}`},
+		{"nested typed document", document.NewDocumentValue(document.NewFieldBuffer().Add("$document", document.NewDocumentValue(document.NewFieldBuffer().Add("$int64", document.NewInt8Value(1))))), `{"$document": {"$document": {"$document": {"$int64": 1}}}}`},
+		{"untyped document", document.NewDocumentValue(document.NewFieldBuffer().Add("$foo", document.NewInt8Value(1))), `{"$foo": 1}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.v)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, string(data))
+
+			var v document.Value
+			err = json.Unmarshal(data, &v)
+			require.NoError(t, err)
+			require.Equal(t, test.v.Type, v.Type)
+			if test.name == "NaN" {
+				require.True(t, math.IsNaN(v.V.(float64)))
+				return
+			}
+
+			requireEqualJSONValue(t, test.v, v)
+		})
+	}
+
+	t.Run("integer size", func(t *testing.T) {
+		var v document.Value
+		err := json.Unmarshal([]byte(`10`), &v)
+		require.NoError(t, err)
+		require.Equal(t, document.NewInt8Value(10), v)
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := document.ToJSON(&buf, document.NewFieldBuffer().
+			Add("a", document.NewInt64Value(10)).
+			Add("b", document.NewBlobValue([]byte{0, 1, 0xff})).
+			Add("c", document.NewFloat64Value(10)))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a": 10, "b": "AAH/", "c": 10}`, buf.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := []string{
+			`{"a":`,
+			`{"$blob": 10}`,
+			`{"$blob": "@@"}`,
+			`{"$int8": 1000}`,
+			`{"$document": 10}`,
+			`{"$time": "foo"}`,
+		}
+
+		for _, test := range tests {
+			var v document.Value
+			err := json.Unmarshal([]byte(test), &v)
+			require.Error(t, err, test)
+		}
+	})
+}
+
+// requireEqualJSONValue checks that a and b have the same type and value,
+// recursively.
+func requireEqualJSONValue(t *testing.T, a, b document.Value) {
+	t.Helper()
+
+	require.Equal(t, a.Type, b.Type)
+
+	switch a.Type {
+	case document.DocumentValue:
+		var fields []string
+		err := a.V.(document.Document).Iterate(func(f string, va document.Value) error {
+			fields = append(fields, f)
+			vb, err := b.V.(document.Document).GetByField(f)
+			require.NoError(t, err)
+			requireEqualJSONValue(t, va, vb)
+			return nil
+		})
+		require.NoError(t, err)
+		var bfields []string
+		err = b.V.(document.Document).Iterate(func(f string, _ document.Value) error {
+			bfields = append(bfields, f)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, fields, bfields)
+	case document.ArrayValue:
+		la, err := document.ArrayLength(a.V.(document.Array))
+		require.NoError(t, err)
+		lb, err := document.ArrayLength(b.V.(document.Array))
+		require.NoError(t, err)
+		require.Equal(t, la, lb)
+		err = a.V.(document.Array).Iterate(func(i int, va document.Value) error {
+			vb, err := b.V.(document.Array).GetByIndex(i)
+			require.NoError(t, err)
+			requireEqualJSONValue(t, va, vb)
+			return nil
+		})
+		require.NoError(t, err)
+	default:
+		require.Equal(t, a, b)
+	}
+}
+
+// mustIPValue returns the IP value of ip and panics if it is invalid.
+func mustIPValue(ip net.IP) document.Value {
+	v, err := document.NewIPValue(ip)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/asdine/genji/document"
@@ -24,6 +26,9 @@ const (
 // Text and Blob values are stored in Bytes indexes.
 // Signed, unsigned integers, and floats are stored in Float indexes.
 // Booleans are stores in Bool indexes.
+// IP addresses are stored in IP indexes.
+// UUIDs are stored in UUID indexes.
+// Times are stored in Time indexes.
 type Type byte
 
 // index value types
@@ -32,6 +37,9 @@ const (
 	Bool
 	Float
 	Bytes
+	IP
+	UUID
+	Time
 )
 
 // NewTypeFromValueType returns the right index type associated with t.
@@ -48,6 +56,18 @@ func NewTypeFromValueType(t document.ValueType) Type {
 		return Bool
 	}
 
+	if t == document.IPValue {
+		return IP
+	}
+
+	if t == document.UUIDValue {
+		return UUID
+	}
+
+	if t == document.TimeValue {
+		return Time
+	}
+
 	return Null
 }
 
@@ -110,6 +130,8 @@ func buildIndexName(name string, t Type) string {
 type Pivot struct {
 	Value document.Value
 	empty bool
+	min   bool
+	max   bool
 }
 
 // EmptyPivot returns a pivot that starts at the beginning of any indexed values compatible with the given type.
@@ -121,6 +143,28 @@ func EmptyPivot(t document.ValueType) *Pivot {
 	}
 }
 
+// MinValue returns a pivot that sorts before any indexed value compatible with the given type.
+// Ascending from it goes through all the values of that type, while descending from it
+// returns nothing.
+// Values are grouped by index type, so the minimum of any number type sorts before
+// every number of the index, regardless of its size.
+func MinValue(t document.ValueType) *Pivot {
+	p := EmptyPivot(t)
+	p.min = true
+	return p
+}
+
+// MaxValue returns a pivot that sorts after any indexed value compatible with the given type.
+// Descending from it goes through all the values of that type, while ascending from it
+// returns nothing.
+// Values are grouped by index type, so the maximum of any number type sorts after
+// every number of the index, regardless of its size.
+func MaxValue(t document.ValueType) *Pivot {
+	p := EmptyPivot(t)
+	p.max = true
+	return p
+}
+
 // ListIndex is an implementation that associates a value with a list of keys.
 type ListIndex struct {
 	tx   engine.Transaction
@@ -174,7 +218,7 @@ func (i *ListIndex) Delete(val document.Value, key []byte) error {
 func (i *ListIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
 	// iterate over all stores in order
 	if pivot == nil {
-		for t := Null; t <= Bytes; t++ {
+		for t := Null; t <= Time; t++ {
 			st, err := getStore(i.tx, t, i.name)
 			if err != nil {
 				return err
@@ -204,7 +248,7 @@ func (i *ListIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Valu
 	if err != nil {
 		return err
 	}
-	if st == nil {
+	if st == nil || pivot.max {
 		return nil
 	}
 
@@ -233,7 +277,7 @@ func (i *ListIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Valu
 func (i *ListIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
 	// iterate over all stores in order
 	if pivot == nil {
-		for t := Bytes; t >= Null; t-- {
+		for t := Time; t >= Null; t-- {
 			st, err := getStore(i.tx, t, i.name)
 			if err != nil {
 				return err
@@ -263,7 +307,7 @@ func (i *ListIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value,
 	if err != nil {
 		return err
 	}
-	if st == nil {
+	if st == nil || pivot.min {
 		return nil
 	}
 
@@ -293,17 +337,14 @@ func (i *ListIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value,
 
 // Truncate deletes all the index data.
 func (i *ListIndex) Truncate() error {
-	err := dropStore(i.tx, Float, i.name)
-	if err != nil {
-		return err
-	}
-
-	err = dropStore(i.tx, Bytes, i.name)
-	if err != nil {
-		return err
+	for t := Null; t <= Time; t++ {
+		err := dropStore(i.tx, t, i.name)
+		if err != nil {
+			return err
+		}
 	}
 
-	return dropStore(i.tx, Bool, i.name)
+	return nil
 }
 
 // UniqueIndex is an implementation that associates a value with a exactly one key.
@@ -367,7 +408,7 @@ func (i *UniqueIndex) Delete(val document.Value, key []byte) error {
 func (i *UniqueIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
 	// iterate over all stores in order
 	if pivot == nil {
-		for t := Null; t <= Bytes; t++ {
+		for t := Null; t <= Time; t++ {
 			st, err := getStore(i.tx, t, i.name)
 			if err != nil {
 				return err
@@ -396,7 +437,7 @@ func (i *UniqueIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Va
 	if err != nil {
 		return err
 	}
-	if st == nil {
+	if st == nil || pivot.max {
 		return nil
 	}
 
@@ -429,7 +470,7 @@ func (i *UniqueIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Va
 func (i *UniqueIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
 	// iterate over all stores in order
 	if pivot == nil {
-		for t := Bytes; t >= Null; t-- {
+		for t := Time; t >= Null; t-- {
 			st, err := getStore(i.tx, t, i.name)
 			if err != nil {
 				return err
@@ -458,7 +499,7 @@ func (i *UniqueIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Valu
 	if err != nil {
 		return err
 	}
-	if st == nil {
+	if st == nil || pivot.min {
 		return nil
 	}
 
@@ -488,46 +529,310 @@ func (i *UniqueIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Valu
 
 // Truncate deletes all the index data.
 func (i *UniqueIndex) Truncate() error {
-	err := dropStore(i.tx, Float, i.name)
+	for t := Null; t <= Time; t++ {
+		err := dropStore(i.tx, t, i.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EncodeFieldToIndexValue returns a byte array that represents the value in such
+// a way that can be compared for ordering and indexing
+func EncodeFieldToIndexValue(val document.Value) ([]byte, error) {
+	if val.V != nil && val.Type.IsNumber() {
+		return encodeNumber(val)
+	}
+
+	switch val.Type {
+	case document.DocumentValue:
+		d, err := val.ConvertToDocument()
+		if err != nil {
+			return nil, err
+		}
+		return encodeDocument(d)
+	case document.ArrayValue:
+		a, err := val.ConvertToArray()
+		if err != nil {
+			return nil, err
+		}
+		return encodeArray(a)
+	}
+
+	return encoding.EncodeValue(val)
+}
+
+// encodeDocument encodes a document so that the order of encoded documents follows
+// the order in which the document package compares them: fields are sorted by name
+// and compared pair by pair, by name then by value, and a document whose fields
+// are the first fields of another one sorts before it.
+// Documents with the same fields and values have the same encoded value,
+// whatever the order of their fields.
+func encodeDocument(d document.Document) ([]byte, error) {
+	type field struct {
+		name string
+		v    document.Value
+	}
+
+	var fields []field
+	err := d.Iterate(func(f string, v document.Value) error {
+		fields = append(fields, field{f, v})
+		return nil
+	})
 	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+
+	buf := []byte{byte(document.DocumentValue)}
+	for _, f := range fields {
+		buf = appendEscaped(buf, []byte(f.name))
+		buf, err = appendNestedValue(buf, f.v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf, nil
+}
+
+// encodeArray encodes an array so that arrays are ordered by their values, one by one,
+// and an array whose values are the first values of another one sorts before it.
+func encodeArray(a document.Array) ([]byte, error) {
+	buf := []byte{byte(document.ArrayValue)}
+	err := a.Iterate(func(i int, v document.Value) error {
+		var err error
+		buf, err = appendNestedValue(buf, v)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	err = dropStore(i.tx, Bytes, i.name)
+	return buf, nil
+}
+
+// appendNestedValue appends the index type and the escaped encoded value of v to buf.
+func appendNestedValue(buf []byte, v document.Value) ([]byte, error) {
+	enc, err := EncodeFieldToIndexValue(v)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return dropStore(i.tx, Bool, i.name)
+	buf = append(buf, byte(NewTypeFromValueType(v.Type)))
+	return appendEscaped(buf, enc), nil
 }
 
-// EncodeFieldToIndexValue returns a byte array that represents the value in such
-// a way that can be compared for ordering and indexing
-func EncodeFieldToIndexValue(val document.Value) ([]byte, error) {
-	if val.V != nil && val.Type.IsNumber() && val.Type != document.Float64Value {
-		x, err := val.ConvertToFloat64()
+// appendEscaped appends data to buf followed by a terminator, so that escaped values
+// follow the order of the original values and none of them is the prefix of another one.
+// Zero bytes are escaped as 0x00 0xFF and the terminator is 0x00 0x01.
+func appendEscaped(buf, data []byte) []byte {
+	for _, b := range data {
+		if b == 0 {
+			buf = append(buf, 0, 0xFF)
+			continue
+		}
+
+		buf = append(buf, b)
+	}
+
+	return append(buf, 0, 1)
+}
+
+// decodeDocument decodes documents encoded by encodeDocument.
+// Their fields are decoded in the order of their names.
+func decodeDocument(data []byte) (document.Value, error) {
+	fb := document.NewFieldBuffer()
+
+	data = data[1:]
+	for len(data) > 0 {
+		name, n, err := readEscaped(data)
 		if err != nil {
-			return nil, err
+			return document.Value{}, err
 		}
+		data = data[n:]
 
-		return encoding.EncodeFloat64(x), nil
+		v, n, err := readNestedValue(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		data = data[n:]
+
+		fb.Add(string(name), v)
 	}
 
-	return encoding.EncodeValue(val)
+	return document.NewDocumentValue(fb), nil
+}
+
+// decodeArray decodes arrays encoded by encodeArray.
+func decodeArray(data []byte) (document.Value, error) {
+	vb := document.NewValueBuffer()
+
+	data = data[1:]
+	for len(data) > 0 {
+		v, n, err := readNestedValue(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		data = data[n:]
+
+		vb = vb.Append(v)
+	}
+
+	return document.NewArrayValue(vb), nil
+}
+
+// readNestedValue decodes the value at the beginning of data, encoded by appendNestedValue,
+// and returns the number of bytes read.
+func readNestedValue(data []byte) (document.Value, int, error) {
+	if len(data) == 0 {
+		return document.Value{}, 0, errors.New("missing nested value")
+	}
+
+	enc, n, err := readEscaped(data[1:])
+	if err != nil {
+		return document.Value{}, 0, err
+	}
+
+	v, err := decodeIndexValueToField(Type(data[0]), enc)
+	return v, n + 1, err
+}
+
+// readEscaped unescapes the data at the beginning of buf, written by appendEscaped,
+// and returns the number of bytes read, terminator included.
+func readEscaped(buf []byte) ([]byte, int, error) {
+	var data []byte
+
+	for i := 0; i < len(buf); i++ {
+		if buf[i] != 0 {
+			data = append(data, buf[i])
+			continue
+		}
+
+		if i+1 == len(buf) {
+			break
+		}
+
+		i++
+		switch buf[i] {
+		case 0xFF:
+			data = append(data, 0)
+		case 1:
+			return data, i + 1, nil
+		default:
+			return nil, 0, fmt.Errorf("invalid escaped byte %#x", buf[i])
+		}
+	}
+
+	return nil, 0, errors.New("missing terminator of escaped value")
+}
+
+// encodeNumber encodes numbers of any type so that their encoded values follow
+// their numeric order and numbers that are equal share the same encoded value.
+// Numbers are encoded as float64, negative zero as zero and all NaNs alike,
+// which keeps the format of indexes created before integers were encoded
+// exactly. Integers that can't be represented
+// exactly by a float64 are encoded as the largest float64 lower than them,
+// followed by numberDeltaMarker and the difference between the integer and that
+// float64, which is always lower than 2048. Since numberDeltaMarker is greater
+// than the separator of list indexes and than the terminator of nested values,
+// these integers are sorted right after the float64 they are encoded with.
+func encodeNumber(val document.Value) ([]byte, error) {
+	if val.Type == document.Float64Value {
+		f := val.V.(float64)
+		// negative zero is encoded as zero, as they are equal
+		if f == 0 {
+			f = 0
+		}
+		// all NaNs are encoded alike, as they are equal and greater than any other number
+		if math.IsNaN(f) {
+			f = math.NaN()
+		}
+
+		return encoding.EncodeFloat64(f), nil
+	}
+
+	x, err := val.ConvertToInt64()
+	if err != nil {
+		return nil, err
+	}
+
+	f := float64(x)
+	if f >= math.MaxInt64 || int64(f) > x {
+		// x was rounded up
+		f = math.Nextafter(f, math.Inf(-1))
+	}
+
+	buf := encoding.EncodeFloat64(f)
+	delta := x - int64(f)
+	if delta == 0 {
+		return buf, nil
+	}
+
+	return append(buf, numberDeltaMarker, byte(delta>>8), byte(delta)), nil
+}
+
+// numberDeltaMarker precedes the difference between an integer and the float64 it is encoded with.
+const numberDeltaMarker = 0xFF
+
+// decodeNumber decodes numbers encoded by encodeNumber.
+// Numbers that can be represented exactly by a float64 are decoded as floats,
+// others as integers.
+func decodeNumber(data []byte) (document.Value, error) {
+	if len(data) != 8 && (len(data) != 11 || data[8] != numberDeltaMarker) {
+		return document.Value{}, fmt.Errorf("invalid number of length %d", len(data))
+	}
+
+	f, err := encoding.DecodeFloat64(data[:8])
+	if err != nil {
+		return document.Value{}, err
+	}
+
+	if len(data) == 8 {
+		return document.NewFloat64Value(f), nil
+	}
+
+	delta := int64(data[9])<<8 | int64(data[10])
+	return document.NewInt64Value(int64(f) + delta), nil
 }
 
 func decodeIndexValueToField(t Type, data []byte) (document.Value, error) {
 	switch t {
 	case Null:
+		// documents and arrays are stored alongside null values
+		if len(data) > 0 {
+			switch document.ValueType(data[0]) {
+			case document.DocumentValue:
+				return decodeDocument(data)
+			case document.ArrayValue:
+				return decodeArray(data)
+			}
+		}
 		return document.NewNullValue(), nil
 	case Bytes:
 		return document.NewBlobValue(data), nil
 	case Float:
-		f, err := encoding.DecodeFloat64(data)
-		return document.NewFloat64Value(f), err
+		return decodeNumber(data)
 	case Bool:
 		b, err := encoding.DecodeBool(data)
 		return document.NewBoolValue(b), err
+	case IP:
+		ip, err := encoding.DecodeIP(data)
+		if err != nil {
+			return document.Value{}, err
+		}
+		return document.NewIPValue(ip)
+	case UUID:
+		u, err := encoding.DecodeUUID(data)
+		return document.NewUUIDValue(u), err
+	case Time:
+		t, err := encoding.DecodeTime(data)
+		return document.NewTimeValue(t), err
 	}
 
 	return document.Value{}, fmt.Errorf("unknown index type %d", t)
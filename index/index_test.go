@@ -2,12 +2,18 @@
 package index_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
 	"github.com/asdine/genji/engine/memoryengine"
 	"github.com/asdine/genji/index"
 	"github.com/stretchr/testify/require"
@@ -346,6 +352,424 @@ func TestIndexDescendLessOrEqual(t *testing.T) {
 	}
 }
 
+func TestIndexMinMaxValue(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		t.Run(text+"Ascending from MinValue should iterate over all documents of the same type", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			for i := -5; i < 5; i++ {
+				require.NoError(t, idx.Set(document.NewIntValue(i), []byte{'a' + byte(i+5)}))
+				require.NoError(t, idx.Set(document.NewTextValue(strconv.Itoa(i)), []byte{'s', 'a' + byte(i+5)}))
+			}
+
+			var count int
+			err := idx.AscendGreaterOrEqual(index.MinValue(document.Int64Value), func(val document.Value, key []byte) error {
+				require.Equal(t, document.NewFloat64Value(float64(count-5)), val)
+				count++
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 10, count)
+		})
+
+		t.Run(text+"Descending from MaxValue should iterate over all documents of the same type in reverse order", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			for i := -5; i < 5; i++ {
+				require.NoError(t, idx.Set(document.NewIntValue(i), []byte{'a' + byte(i+5)}))
+				require.NoError(t, idx.Set(document.NewTextValue(strconv.Itoa(i)), []byte{'s', 'a' + byte(i+5)}))
+			}
+
+			var count int
+			err := idx.DescendLessOrEqual(index.MaxValue(document.Int64Value), func(val document.Value, key []byte) error {
+				require.Equal(t, document.NewFloat64Value(float64(4-count)), val)
+				count++
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 10, count)
+		})
+
+		t.Run(text+"Ascending from MaxValue or descending from MinValue should not iterate", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			for i := 0; i < 5; i++ {
+				require.NoError(t, idx.Set(document.NewIntValue(i), []byte{'a' + byte(i)}))
+			}
+
+			err := idx.AscendGreaterOrEqual(index.MaxValue(document.Int64Value), func(val document.Value, key []byte) error {
+				return errors.New("should not iterate")
+			})
+			require.NoError(t, err)
+
+			err = idx.DescendLessOrEqual(index.MinValue(document.Int64Value), func(val document.Value, key []byte) error {
+				return errors.New("should not iterate")
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestIndexIP(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		t.Run(text+"Should iterate over a range of addresses", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			ips := []string{"2001:db8::1", "10.0.1.1", "10.0.0.255", "9.255.255.255", "10.0.0.1"}
+			for i, ip := range ips {
+				require.NoError(t, idx.Set(mustIPValue(net.ParseIP(ip)), []byte{'a' + byte(i)}))
+				require.NoError(t, idx.Set(document.NewTextValue(ip), []byte{'s', 'a' + byte(i)}))
+			}
+
+			var found []string
+			err := idx.AscendGreaterOrEqual(&index.Pivot{Value: mustIPValue(net.ParseIP("10.0.0.0"))}, func(val document.Value, key []byte) error {
+				ok, err := val.IsLesserThanOrEqual(mustIPValue(net.ParseIP("10.0.0.255")))
+				if err != nil || !ok {
+					return err
+				}
+				found = append(found, val.String())
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"10.0.0.1", "10.0.0.255"}, found)
+		})
+
+		t.Run(text+"Should iterate over addresses without pivot", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			require.NoError(t, idx.Set(mustIPValue(net.ParseIP("10.0.0.1")), []byte("a")))
+			require.NoError(t, idx.Set(document.NewTextValue("foo"), []byte("b")))
+
+			var types []document.ValueType
+			err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+				types = append(types, val.Type)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.ValueType{document.BlobValue, document.IPValue}, types)
+
+			types = nil
+			err = idx.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
+				types = append(types, val.Type)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.ValueType{document.IPValue, document.BlobValue}, types)
+		})
+	}
+}
+
+func TestIndexUUID(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		t.Run(text+"Should iterate over uuids in byte order", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			uuids := [][16]byte{{0: 2}, {15: 1}, {0: 1, 15: 2}, {}}
+			for i, u := range uuids {
+				require.NoError(t, idx.Set(document.NewUUIDValue(u), []byte{'a' + byte(i)}))
+			}
+			require.NoError(t, idx.Set(document.NewTextValue("foo"), []byte("z")))
+
+			var found []document.Value
+			err := idx.AscendGreaterOrEqual(&index.Pivot{Value: document.NewUUIDValue([16]byte{15: 1})}, func(val document.Value, key []byte) error {
+				found = append(found, val)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.Value{
+				document.NewUUIDValue([16]byte{15: 1}),
+				document.NewUUIDValue([16]byte{0: 1, 15: 2}),
+				document.NewUUIDValue([16]byte{0: 2}),
+			}, found)
+
+			var types []document.ValueType
+			err = idx.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
+				types = append(types, val.Type)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.ValueType{
+				document.UUIDValue, document.UUIDValue, document.UUIDValue, document.UUIDValue, document.BlobValue,
+			}, types)
+		})
+	}
+}
+
+func TestIndexTime(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		t.Run(text+"Should iterate over times in chronological order", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			times := []time.Time{time.Unix(10, 0), time.Unix(-10, 0), time.Unix(10, 1), {}}
+			for i, tm := range times {
+				require.NoError(t, idx.Set(document.NewTimeValue(tm), []byte{'a' + byte(i)}))
+			}
+			require.NoError(t, idx.Set(document.NewTextValue("foo"), []byte("z")))
+
+			var found []document.Value
+			err := idx.AscendGreaterOrEqual(&index.Pivot{Value: document.NewTimeValue(time.Unix(-10, 0))}, func(val document.Value, key []byte) error {
+				found = append(found, val)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.Value{
+				document.NewTimeValue(time.Unix(-10, 0)),
+				document.NewTimeValue(time.Unix(10, 0)),
+				document.NewTimeValue(time.Unix(10, 1)),
+			}, found)
+
+			var types []document.ValueType
+			err = idx.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
+				types = append(types, val.Type)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.ValueType{
+				document.TimeValue, document.TimeValue, document.TimeValue, document.TimeValue, document.BlobValue,
+			}, types)
+		})
+	}
+}
+
+func TestIndexLargeIntegers(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		t.Run(text+"Should keep integers that can't be represented by a float64 distinct", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			values := []document.Value{
+				document.NewInt64Value(1<<53 + 1),
+				document.NewFloat64Value(1 << 53),
+				document.NewInt64Value(math.MaxInt64),
+				document.NewInt64Value(math.MinInt64),
+				document.NewInt64Value(1<<53 - 1),
+				document.NewFloat64Value(1<<53 + 2),
+				document.NewFloat64Value(0.5),
+			}
+			for i, v := range values {
+				require.NoError(t, idx.Set(v, []byte{'a' + byte(i)}))
+			}
+
+			if unique {
+				require.Equal(t, index.ErrDuplicate, idx.Set(document.NewInt64Value(1<<53), []byte("z")))
+			}
+
+			var found []document.Value
+			err := idx.AscendGreaterOrEqual(&index.Pivot{Value: document.NewInt64Value(1<<53 - 1)}, func(val document.Value, key []byte) error {
+				found = append(found, val)
+				return nil
+			})
+			require.NoError(t, err)
+			// integers that can be represented exactly by a float64 are decoded as floats
+			require.Equal(t, []document.Value{
+				document.NewFloat64Value(1<<53 - 1),
+				document.NewFloat64Value(1 << 53),
+				document.NewInt64Value(1<<53 + 1),
+				document.NewFloat64Value(1<<53 + 2),
+				document.NewInt64Value(math.MaxInt64),
+			}, found)
+
+			found = nil
+			err = idx.DescendLessOrEqual(&index.Pivot{Value: document.NewFloat64Value(1)}, func(val document.Value, key []byte) error {
+				found = append(found, val)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []document.Value{
+				document.NewFloat64Value(0.5),
+				document.NewFloat64Value(math.MinInt64),
+			}, found)
+		})
+	}
+}
+
+func TestIndexDocumentsAndArrays(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Unique: %v, Should decode documents and arrays", unique), func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			var values []document.Value
+			for _, js := range []string{`null`, `[1, "a\u0000b", [true]]`, `{"b": {"c": null}, "a": 1.5}`} {
+				var v document.Value
+				require.NoError(t, json.Unmarshal([]byte(js), &v))
+				values = append(values, v)
+				require.NoError(t, idx.Set(v, []byte{'a' + byte(len(values))}))
+			}
+
+			var found []document.Value
+			err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+				found = append(found, val)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Len(t, found, len(values))
+			for _, v := range values {
+				var ok bool
+				for _, f := range found {
+					if f.Type == v.Type {
+						ok, err = v.IsEqual(f)
+						require.NoError(t, err)
+						break
+					}
+				}
+				require.True(t, ok, "%s not found in %v", v, found)
+			}
+		})
+	}
+}
+
+func TestEncodeFieldToIndexValueNumberFormat(t *testing.T) {
+	// numbers that can be represented exactly by a float64 keep the format
+	// of indexes created before integers were encoded exactly
+	for _, v := range []document.Value{
+		document.NewInt8Value(-10),
+		document.NewInt64Value(1 << 53),
+		document.NewInt64Value(math.MinInt64),
+		document.NewFloat64Value(1.5),
+	} {
+		f, err := v.ConvertToFloat64()
+		require.NoError(t, err)
+
+		enc, err := index.EncodeFieldToIndexValue(v)
+		require.NoError(t, err)
+		require.Equal(t, encoding.EncodeFloat64(f), enc)
+	}
+
+	// negative zero and zero, and all NaNs, are equal and share the same encoded value
+	equal := [][]document.Value{
+		{document.NewFloat64Value(0), document.NewFloat64Value(math.Copysign(0, -1)), document.NewInt8Value(0)},
+		{document.NewFloat64Value(math.NaN()), document.NewFloat64Value(math.Copysign(math.NaN(), -1)), document.NewFloat64Value(math.Float64frombits(0x7FF0000000000001))},
+	}
+	for _, values := range equal {
+		first, err := index.EncodeFieldToIndexValue(values[0])
+		require.NoError(t, err)
+		for _, v := range values[1:] {
+			enc, err := index.EncodeFieldToIndexValue(v)
+			require.NoError(t, err)
+			require.Equal(t, first, enc, "%v", v)
+		}
+	}
+
+	// NaN is greater than any other number
+	inf, err := index.EncodeFieldToIndexValue(document.NewFloat64Value(math.Inf(1)))
+	require.NoError(t, err)
+	nan, err := index.EncodeFieldToIndexValue(document.NewFloat64Value(math.Copysign(math.NaN(), -1)))
+	require.NoError(t, err)
+	require.Equal(t, -1, bytes.Compare(inf, nan))
+
+	for _, x := range []int64{1<<53 + 1, -(1<<53 + 1), math.MaxInt64, math.MinInt64 + 1} {
+		enc, err := index.EncodeFieldToIndexValue(document.NewInt64Value(x))
+		require.NoError(t, err)
+		require.Len(t, enc, 11)
+	}
+}
+
+func TestEncodeFieldToIndexValueOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+	}{
+		{"documents", []string{
+			`{}`,
+			`{"a": 0, "c": 5}`,
+			`{"a": 1}`,
+			`{"b": 1, "a": 1}`,
+			`{"b": 2, "a": 1}`,
+			`{"a": 1, "b": 2, "c": {"d": 0}}`,
+			`{"a": 1, "b": 2, "c": {"d": 1}}`,
+			`{"b": 0}`,
+		}},
+		{"arrays", []string{
+			`[]`,
+			`[1]`,
+			`[1, 1]`,
+			`[1, 2]`,
+			`[1.5]`,
+			`[2, [1]]`,
+			`[2, [1, 0]]`,
+		}},
+		{"numbers", []string{
+			`-9223372036854775808`,
+			`-9007199254740993`,
+			`-9007199254740992`,
+			`-0.5`,
+			`9007199254740992`,
+			`9007199254740993`,
+			`9007199254740994`,
+			`9007199254740995`,
+			`9223372036854775806`,
+			`9223372036854775807`,
+			`1e300`,
+		}},
+		{"numbers in arrays", []string{
+			`[9007199254740992]`,
+			`[9007199254740992, 1]`,
+			`[9007199254740993]`,
+			`[9007199254740994]`,
+		}},
+		{"texts", []string{
+			`["a"]`,
+			`["a\u0000"]`,
+			`["a\u0000", "b"]`,
+			`["a\u0001"]`,
+			`["ab"]`,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := make([]document.Value, len(test.values))
+			encoded := make([][]byte, len(test.values))
+			for i, js := range test.values {
+				require.NoError(t, json.Unmarshal([]byte(js), &values[i]))
+
+				var err error
+				encoded[i], err = index.EncodeFieldToIndexValue(values[i])
+				require.NoError(t, err)
+			}
+
+			for i := range values {
+				for j := i + 1; j < len(values); j++ {
+					ok, err := values[i].IsLesserThan(values[j])
+					require.NoError(t, err)
+					require.True(t, ok, "%s < %s", test.values[i], test.values[j])
+					require.Equal(t, -1, bytes.Compare(encoded[i], encoded[j]), "%s < %s", test.values[i], test.values[j])
+				}
+			}
+		})
+	}
+
+	t.Run("equal documents", func(t *testing.T) {
+		var a, b document.Value
+		require.NoError(t, json.Unmarshal([]byte(`{"a": 1, "b": [{"c": 2, "d": 3}]}`), &a))
+		require.NoError(t, json.Unmarshal([]byte(`{"b": [{"d": 3, "c": 2.0}], "a": 1.0}`), &b))
+
+		ea, err := index.EncodeFieldToIndexValue(a)
+		require.NoError(t, err)
+		eb, err := index.EncodeFieldToIndexValue(b)
+		require.NoError(t, err)
+		require.Equal(t, ea, eb)
+	})
+}
+
 // BenchmarkIndexSet benchmarks the Set method with 1, 10, 1000 and 10000 successive insertions.
 func BenchmarkIndexSet(b *testing.B) {
 	for size := 10; size <= 10000; size *= 10 {
@@ -389,3 +813,13 @@ func BenchmarkIndexIteration(b *testing.B) {
 		})
 	}
 }
+
+// mustIPValue returns the IP value of ip and panics if it is invalid.
+func mustIPValue(ip net.IP) document.Value {
+	v, err := document.NewIPValue(ip)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
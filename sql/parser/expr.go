@@ -86,6 +86,8 @@ func opToExpr(op scanner.Token, lhs, rhs query.Expr) query.Expr {
 		return query.Lt(lhs, rhs)
 	case scanner.LTE:
 		return query.Lte(lhs, rhs)
+	case scanner.CONTAINS:
+		return query.Contains(lhs, rhs)
 	case scanner.AND:
 		return query.And(lhs, rhs)
 	case scanner.OR:
@@ -433,9 +435,19 @@ func (p *Parser) parseFunction() (query.Expr, error) {
 	}
 
 	// Check if the function is called without arguments.
-	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.RPAREN {
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	if tok == scanner.RPAREN {
 		return query.GetFunc(fname)
 	}
+
+	// Check if the function is called with the * token, e.g. COUNT(*).
+	if tok == scanner.MUL {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.RPAREN {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{")"}, pos)
+		}
+
+		return query.GetFunc(fname, query.Wildcard{})
+	}
 	p.Unscan()
 
 	var exprs []query.Expr
@@ -449,10 +461,13 @@ func (p *Parser) parseFunction() (query.Expr, error) {
 
 		exprs = append(exprs, expr)
 
-		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
-			p.Unscan()
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok == scanner.RPAREN {
 			return query.GetFunc(fname, exprs...)
 		}
+		if tok != scanner.COMMA {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{",", ")"}, pos)
+		}
 	}
 }
 
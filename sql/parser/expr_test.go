@@ -118,6 +118,7 @@ func TestParserExpr(t *testing.T) {
 		{">=", "age >= 10", query.Gte(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"<", "age < 10", query.Lt(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"<=", "age <= 10", query.Lte(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
+		{"CONTAINS", "tags CONTAINS 'go'", query.Contains(query.FieldSelector([]string{"tags"}), query.TextValue("go")), false},
 		{"+", "age + 10", query.Add(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"-", "age - 10", query.Sub(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"*", "age * 10", query.Mul(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
@@ -151,6 +152,14 @@ func TestParserExpr(t *testing.T) {
 			), false},
 		{"with NULL", "age > NULL", query.Gt(query.FieldSelector([]string{"age"}), query.NullValue()), false},
 		{"pk() function", "pk()", &query.PKFunc{}, false},
+		{"COUNT(*)", "COUNT(*)", query.CountFunc{}, false},
+		{"COUNT(a)", "COUNT(a.b)", query.CountFunc{Path: query.FieldSelector([]string{"a", "b"})}, false},
+		{"sum(a)", "sum(a)", query.SumFunc{Path: query.FieldSelector([]string{"a"})}, false},
+		{"AVG(a)", "AVG(a)", query.AvgFunc{Path: query.FieldSelector([]string{"a"})}, false},
+		{"MIN(a)", "MIN(a)", query.MinFunc{Path: query.FieldSelector([]string{"a"})}, false},
+		{"MAX(a)", "MAX(a)", query.MaxFunc{Path: query.FieldSelector([]string{"a"})}, false},
+		{"SUM without field", "SUM(1)", nil, true},
+		{"MAX with too many arguments", "MAX(a, b)", nil, true},
 		{"CAST", "CAST(a.b.1.0 AS TEXT)", query.Cast{Expr: query.FieldSelector([]string{"a", "b", "1", "0"}), ConvertTo: document.TextValue}, false},
 	}
 
@@ -168,6 +177,24 @@ func TestParserExpr(t *testing.T) {
 	}
 }
 
+func TestParserFunctionArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"missing closing parenthesis", "pk(a"},
+		{"missing comma", "pk(a b)"},
+		{"trailing comma", "pk(a,)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := NewParser(strings.NewReader(test.s)).parseExpr()
+			require.IsType(t, &ParseError{}, err)
+		})
+	}
+}
+
 func TestParserParams(t *testing.T) {
 	tests := []struct {
 		name     string
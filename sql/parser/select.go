@@ -30,6 +30,18 @@ func (p *Parser) parseSelectStatement() (query.SelectStmt, error) {
 		return stmt, err
 	}
 
+	// Parse group by: "GROUP BY fieldRef"
+	stmt.GroupBy, err = p.parseGroupBy()
+	if err != nil {
+		return stmt, err
+	}
+
+	// Parse having: "HAVING EXPR"
+	stmt.HavingExpr, err = p.parseHaving()
+	if err != nil {
+		return stmt, err
+	}
+
 	// Parse order by: "ORDER BY fieldRef [ASC|DESC]?"
 	stmt.OrderBy, stmt.OrderByDirection, err = p.parseOrderBy()
 	if err != nil {
@@ -115,6 +127,38 @@ func (p *Parser) parseFrom() (string, bool, error) {
 	return ident, true, err
 }
 
+func (p *Parser) parseGroupBy() (query.FieldSelector, error) {
+	// parse GROUP token
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.GROUP {
+		p.Unscan()
+		return nil, nil
+	}
+
+	// parse BY token
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.BY {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"BY"}, pos)
+	}
+
+	// parse field reference
+	ref, err := p.parseFieldRef()
+	if err != nil {
+		return nil, err
+	}
+
+	return query.FieldSelector(ref), nil
+}
+
+func (p *Parser) parseHaving() (query.Expr, error) {
+	// parse HAVING token
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.HAVING {
+		p.Unscan()
+		return nil, nil
+	}
+
+	expr, _, err := p.parseExpr()
+	return expr, err
+}
+
 func (p *Parser) parseOrderBy() (query.FieldSelector, scanner.Token, error) {
 	// parse ORDER token
 	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.ORDER {
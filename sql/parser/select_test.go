@@ -73,6 +73,30 @@ func TestParserSelect(t *testing.T) {
 				OrderBy:          []string{"a", "b", "c"},
 				OrderByDirection: scanner.DESC,
 			}, false},
+		{"WithGroupBy", "SELECT a.b, COUNT(*) FROM test WHERE age = 10 GROUP BY a.b ORDER BY a.b",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a", "b"}), ExprName: "a.b"},
+					query.ResultFieldExpr{Expr: query.CountFunc{}, ExprName: "COUNT(*)"},
+				},
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+				GroupBy:   []string{"a", "b"},
+				OrderBy:   []string{"a", "b"},
+			}, false},
+		{"WithGroupBy without BY", "SELECT * FROM test GROUP a", nil, true},
+		{"WithHaving", "SELECT a, COUNT(*) FROM test GROUP BY a HAVING COUNT(*) > 5 ORDER BY a",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"},
+					query.ResultFieldExpr{Expr: query.CountFunc{}, ExprName: "COUNT(*)"},
+				},
+				GroupBy:    []string{"a"},
+				HavingExpr: query.Gt(query.CountFunc{}, query.IntValue(5)),
+				OrderBy:    []string{"a"},
+			}, false},
+		{"WithHaving without expr", "SELECT * FROM test GROUP BY a HAVING", nil, true},
 		{"WithLimit", "SELECT * FROM test WHERE age = 10 LIMIT 20",
 			query.SelectStmt{
 				Selectors: []query.ResultField{query.Wildcard{}},
@@ -243,6 +243,8 @@ func (op *simpleOperator) eval(ctx EvalStack) (document.Value, document.Value, e
 }
 
 // A CmpOp is a comparison operator.
+// Values whose types can't be compared together, like an integer and a text,
+// are considered different: the comparison evaluates to true for != and to false otherwise.
 type CmpOp struct {
 	*simpleOperator
 }
@@ -282,6 +284,12 @@ func Lte(a, b Expr) CmpOp {
 	return CmpOp{&simpleOperator{a, b, scanner.LTE}}
 }
 
+// Contains creates an expression that evaluates to true if a is an array containing b.
+// If a is not an array, it evaluates to false.
+func Contains(a, b Expr) CmpOp {
+	return CmpOp{&simpleOperator{a, b, scanner.CONTAINS}}
+}
+
 // Eval compares a and b together using the operator specified when constructing the CmpOp
 // and returns the result of the comparison.
 func (op CmpOp) Eval(ctx EvalStack) (document.Value, error) {
@@ -311,6 +319,15 @@ func (op CmpOp) Eval(ctx EvalStack) (document.Value, error) {
 	}
 
 	ok, err := op.compare(v1, v2)
+	if _, incompatible := err.(*document.ErrIncompatibleTypes); incompatible {
+		// documents of a table don't necessarily share the same types,
+		// values of incompatible types are considered different
+		if op.Token == scanner.NEQ {
+			return trueLitteral, nil
+		}
+
+		return falseLitteral, nil
+	}
 	if ok {
 		return trueLitteral, err
 	}
@@ -332,11 +349,49 @@ func (op CmpOp) compare(l, r document.Value) (bool, error) {
 		return l.IsLesserThan(r)
 	case scanner.LTE:
 		return l.IsLesserThanOrEqual(r)
+	case scanner.CONTAINS:
+		if l.Type != document.ArrayValue {
+			return false, nil
+		}
+		return l.Contains(r)
 	default:
 		panic(fmt.Sprintf("unknown token %v", op.Token))
 	}
 }
 
+// IsNullOp is an expression that evaluates to true if a field is null or doesn't exist.
+type IsNullOp struct {
+	Expr Expr
+	// Not reverses the result of the expression.
+	Not bool
+}
+
+// IsNull creates an expression that evaluates to true if e evaluates to null
+// or selects a field that doesn't exist.
+func IsNull(e Expr) IsNullOp {
+	return IsNullOp{Expr: e}
+}
+
+// IsNotNull creates an expression that evaluates to true if e evaluates to a value
+// that is not null. It evaluates to false if e selects a field that doesn't exist.
+func IsNotNull(e Expr) IsNullOp {
+	return IsNullOp{Expr: e, Not: true}
+}
+
+// Eval implements the Expr interface.
+func (op IsNullOp) Eval(ctx EvalStack) (document.Value, error) {
+	v, err := op.Expr.Eval(ctx)
+	if err != nil && err != document.ErrFieldNotFound {
+		return falseLitteral, err
+	}
+
+	if (err == document.ErrFieldNotFound || v.IsNull()) != op.Not {
+		return trueLitteral, nil
+	}
+
+	return falseLitteral, nil
+}
+
 // AndOp is the And operator.
 type AndOp struct {
 	*simpleOperator
@@ -571,11 +626,25 @@ var functions = map[string]func(args ...Expr) (Expr, error){
 		}
 		return new(PKFunc), nil
 	},
+	"count": func(args ...Expr) (Expr, error) {
+		if len(args) == 1 {
+			if _, ok := args[0].(Wildcard); ok {
+				return CountFunc{}, nil
+			}
+		}
+
+		return newAggregateFunc("count", func(p FieldSelector) Expr { return CountFunc{Path: p} })(args...)
+	},
+	"sum": newAggregateFunc("sum", func(p FieldSelector) Expr { return SumFunc{Path: p} }),
+	"avg": newAggregateFunc("avg", func(p FieldSelector) Expr { return AvgFunc{Path: p} }),
+	"min": newAggregateFunc("min", func(p FieldSelector) Expr { return MinFunc{Path: p} }),
+	"max": newAggregateFunc("max", func(p FieldSelector) Expr { return MaxFunc{Path: p} }),
 }
 
 // GetFunc return a function expression by name.
+// The name is case insensitive.
 func GetFunc(name string, args ...Expr) (Expr, error) {
-	fn, ok := functions[name]
+	fn, ok := functions[strings.ToLower(name)]
 	if !ok {
 		return nil, fmt.Errorf("no such function: %q", name)
 	}
@@ -0,0 +1,59 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNull(t *testing.T) {
+	d := document.NewFieldBuffer().
+		Add("a", document.NewNullValue()).
+		Add("b", document.NewIntValue(0)).
+		Add("c", document.NewDocumentValue(document.NewFieldBuffer().Add("d", document.NewNullValue())))
+
+	tests := []struct {
+		path   []string
+		isNull bool
+	}{
+		{[]string{"a"}, true},
+		{[]string{"b"}, false},
+		{[]string{"c"}, false},
+		{[]string{"c", "d"}, true},
+		{[]string{"e"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(query.FieldSelector(test.path).Name(), func(t *testing.T) {
+			stack := query.EvalStack{Document: d}
+
+			v, err := query.IsNull(query.FieldSelector(test.path)).Eval(stack)
+			require.NoError(t, err)
+			require.Equal(t, document.NewBoolValue(test.isNull), v)
+
+			v, err = query.IsNotNull(query.FieldSelector(test.path)).Eval(stack)
+			require.NoError(t, err)
+			require.Equal(t, document.NewBoolValue(!test.isNull), v)
+		})
+	}
+}
+
+func TestCmpOpIncompatibleTypes(t *testing.T) {
+	tests := []struct {
+		op       query.Expr
+		expected bool
+	}{
+		{query.Eq(query.IntValue(1), query.TextValue("1")), false},
+		{query.Neq(query.IntValue(1), query.TextValue("1")), true},
+		{query.Gt(query.IntValue(1), query.TextValue("1")), false},
+		{query.Lte(query.IntValue(1), query.TextValue("1")), false},
+	}
+
+	for _, test := range tests {
+		v, err := test.op.Eval(query.EvalStack{})
+		require.NoError(t, err)
+		require.Equal(t, document.NewBoolValue(test.expected), v)
+	}
+}
@@ -0,0 +1,651 @@
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/index"
+)
+
+// An aggregator is an expression that computes a value from all the documents of a group.
+// When a select statement is grouped, an accumulator is created for each aggregator
+// and each group, and is fed with every document of the group.
+// The result is stored in the group document under the name returned by String,
+// which is where Eval reads it from.
+type aggregator interface {
+	Expr
+
+	String() string
+	newAccumulator() accumulator
+}
+
+type accumulator interface {
+	add(stack EvalStack) error
+	value() document.Value
+}
+
+func evalAggregator(a aggregator, stack EvalStack) (document.Value, error) {
+	if stack.Document == nil {
+		return nilLitteral, fmt.Errorf("%s can only be used in the result fields of a select statement", a)
+	}
+
+	return stack.Document.GetByField(a.String())
+}
+
+// evalPath returns the value at path p of the current document.
+// It returns a null value if the field doesn't exist.
+func evalPath(p FieldSelector, stack EvalStack) (document.Value, error) {
+	v, err := p.Eval(stack)
+	if err == document.ErrFieldNotFound {
+		return nilLitteral, nil
+	}
+
+	return v, err
+}
+
+// CountFunc is the COUNT aggregate function.
+// It counts the documents of a group that have a non-null value at Path,
+// or all the documents of the group if Path is nil, which is the case of COUNT(*).
+type CountFunc struct {
+	Path FieldSelector
+}
+
+// Eval returns the count computed for the current group.
+func (c CountFunc) Eval(stack EvalStack) (document.Value, error) {
+	return evalAggregator(c, stack)
+}
+
+func (c CountFunc) String() string {
+	if c.Path == nil {
+		return "COUNT(*)"
+	}
+
+	return fmt.Sprintf("COUNT(%s)", c.Path.Name())
+}
+
+func (c CountFunc) newAccumulator() accumulator {
+	return &countAccumulator{path: c.Path}
+}
+
+type countAccumulator struct {
+	path  FieldSelector
+	count int64
+}
+
+func (c *countAccumulator) add(stack EvalStack) error {
+	if c.path != nil {
+		v, err := evalPath(c.path, stack)
+		if err != nil || v.IsNull() {
+			return err
+		}
+	}
+
+	c.count++
+	return nil
+}
+
+func (c *countAccumulator) value() document.Value {
+	return document.NewInt64Value(c.count)
+}
+
+// SumFunc is the SUM aggregate function.
+// It returns the sum of the numbers found at Path in the documents of a group, other values are ignored.
+// The result is an integer if all the numbers are integers and the sum doesn't overflow,
+// a float otherwise. If the group contains no number, the result is null.
+type SumFunc struct {
+	Path FieldSelector
+}
+
+// Eval returns the sum computed for the current group.
+func (s SumFunc) Eval(stack EvalStack) (document.Value, error) {
+	return evalAggregator(s, stack)
+}
+
+func (s SumFunc) String() string {
+	return fmt.Sprintf("SUM(%s)", s.Path.Name())
+}
+
+func (s SumFunc) newAccumulator() accumulator {
+	return &sumAccumulator{path: s.Path}
+}
+
+type sumAccumulator struct {
+	path    FieldSelector
+	found   bool
+	isFloat bool
+	i       int64
+	f       float64
+}
+
+func (s *sumAccumulator) add(stack EvalStack) error {
+	v, err := evalPath(s.path, stack)
+	if err != nil || !v.Type.IsNumber() {
+		return err
+	}
+
+	s.found = true
+
+	if v.Type.IsFloat() {
+		if !s.isFloat {
+			s.isFloat = true
+			s.f = float64(s.i)
+		}
+		s.f += v.V.(float64)
+		return nil
+	}
+
+	x, err := v.ConvertToInt64()
+	if err != nil {
+		return err
+	}
+
+	if s.isFloat {
+		s.f += float64(x)
+		return nil
+	}
+
+	if (x > 0 && s.i > math.MaxInt64-x) || (x < 0 && s.i < math.MinInt64-x) {
+		s.isFloat = true
+		s.f = float64(s.i) + float64(x)
+		return nil
+	}
+
+	s.i += x
+	return nil
+}
+
+func (s *sumAccumulator) value() document.Value {
+	switch {
+	case !s.found:
+		return nilLitteral
+	case s.isFloat:
+		return document.NewFloat64Value(s.f)
+	}
+
+	return document.NewInt64Value(s.i)
+}
+
+// AvgFunc is the AVG aggregate function.
+// It returns the average of the numbers found at Path in the documents of a group as a float,
+// other values are ignored. If the group contains no number, the result is null.
+type AvgFunc struct {
+	Path FieldSelector
+}
+
+// Eval returns the average computed for the current group.
+func (a AvgFunc) Eval(stack EvalStack) (document.Value, error) {
+	return evalAggregator(a, stack)
+}
+
+func (a AvgFunc) String() string {
+	return fmt.Sprintf("AVG(%s)", a.Path.Name())
+}
+
+func (a AvgFunc) newAccumulator() accumulator {
+	return &avgAccumulator{path: a.Path}
+}
+
+type avgAccumulator struct {
+	path  FieldSelector
+	sum   float64
+	count int64
+}
+
+func (a *avgAccumulator) add(stack EvalStack) error {
+	v, err := evalPath(a.path, stack)
+	if err != nil || !v.Type.IsNumber() {
+		return err
+	}
+
+	x, err := v.ConvertToFloat64()
+	if err != nil {
+		return err
+	}
+
+	a.sum += x
+	a.count++
+	return nil
+}
+
+func (a *avgAccumulator) value() document.Value {
+	if a.count == 0 {
+		return nilLitteral
+	}
+
+	return document.NewFloat64Value(a.sum / float64(a.count))
+}
+
+// MinFunc is the MIN aggregate function.
+// It returns the smallest non-null value found at Path in the documents of a group,
+// or null if there is none.
+// Values of different types are ordered like in an index: booleans, numbers, texts and blobs,
+// IPs, UUIDs and times. Documents and arrays are only considered if there are no other values.
+type MinFunc struct {
+	Path FieldSelector
+}
+
+// Eval returns the minimum computed for the current group.
+func (m MinFunc) Eval(stack EvalStack) (document.Value, error) {
+	return evalAggregator(m, stack)
+}
+
+func (m MinFunc) String() string {
+	return fmt.Sprintf("MIN(%s)", m.Path.Name())
+}
+
+func (m MinFunc) newAccumulator() accumulator {
+	return &extremumAccumulator{path: m.Path, cmp: document.Value.IsLesserThan}
+}
+
+// MaxFunc is the MAX aggregate function.
+// It returns the largest non-null value found at Path in the documents of a group,
+// or null if there is none.
+// Values of different types are ordered like in an index: booleans, numbers, texts and blobs,
+// IPs, UUIDs and times. Documents and arrays are only considered if there are no other values.
+type MaxFunc struct {
+	Path FieldSelector
+}
+
+// Eval returns the maximum computed for the current group.
+func (m MaxFunc) Eval(stack EvalStack) (document.Value, error) {
+	return evalAggregator(m, stack)
+}
+
+func (m MaxFunc) String() string {
+	return fmt.Sprintf("MAX(%s)", m.Path.Name())
+}
+
+func (m MaxFunc) newAccumulator() accumulator {
+	return &extremumAccumulator{path: m.Path, cmp: document.Value.IsGreaterThan, max: true}
+}
+
+// extremumAccumulator keeps the value v for which cmp(v, other) is true for every other value
+// of the same index type. Values of different index types are ordered by type, the smallest
+// type winning if max is false and the largest otherwise.
+type extremumAccumulator struct {
+	path  FieldSelector
+	cmp   func(v, other document.Value) (bool, error)
+	max   bool
+	found bool
+	v     document.Value
+}
+
+func (e *extremumAccumulator) add(stack EvalStack) error {
+	v, err := evalPath(e.path, stack)
+	if err != nil || v.IsNull() {
+		return err
+	}
+
+	if !e.found {
+		e.found = true
+		e.v = v
+		return nil
+	}
+
+	vt, et := extremumType(v), extremumType(e.v)
+	if vt != et {
+		switch {
+		case et == 0:
+			// documents and arrays are only kept if there are no other values
+			e.v = v
+		case vt == 0:
+		case (vt < et) != e.max:
+			e.v = v
+		}
+		return nil
+	}
+
+	ok, err := e.cmp(v, e.v)
+	if err != nil {
+		// values of types that can't be compared are ignored
+		return nil
+	}
+	if ok {
+		e.v = v
+	}
+
+	return nil
+}
+
+// extremumType returns the index type of v, which determines the order of values of different types,
+// or zero for documents and arrays, which aren't ordered with other types.
+func extremumType(v document.Value) index.Type {
+	if v.Type == document.DocumentValue || v.Type == document.ArrayValue {
+		return 0
+	}
+
+	return index.NewTypeFromValueType(v.Type)
+}
+
+func (e *extremumAccumulator) value() document.Value {
+	if !e.found {
+		return nilLitteral
+	}
+
+	return e.v
+}
+
+func newAggregateFunc(name string, newFn func(path FieldSelector) Expr) func(args ...Expr) (Expr, error) {
+	return func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() takes one argument", name)
+		}
+
+		fs, ok := args[0].(FieldSelector)
+		if !ok {
+			return nil, fmt.Errorf("the argument of %s() must be a field", name)
+		}
+
+		return newFn(fs), nil
+	}
+}
+
+// collectAggregators returns the aggregators found in the expression tree of e,
+// except those already in aggs.
+func collectAggregators(e Expr, aggs []aggregator) []aggregator {
+	switch t := e.(type) {
+	case aggregator:
+		for _, a := range aggs {
+			if a.String() == t.String() {
+				return aggs
+			}
+		}
+		return append(aggs, t)
+	case interface {
+		LeftHand() Expr
+		RightHand() Expr
+	}:
+		aggs = collectAggregators(t.LeftHand(), aggs)
+		return collectAggregators(t.RightHand(), aggs)
+	case Cast:
+		return collectAggregators(t.Expr, aggs)
+	}
+
+	return aggs
+}
+
+// groupIterator groups the documents of a stream by the value at a given path
+// and returns one document per group. Each group document contains the value of the grouped field
+// at the same path, and the value computed by each aggregator under its name.
+// Documents that don't have the grouped field belong to the same group as the documents
+// whose grouped field is null.
+// If path is nil, all the documents belong to a single group, which is returned even if the
+// stream is empty.
+//
+// If the stream is sorted by the grouped field, groups are computed one at a time
+// and only one group is held in memory. Otherwise, all the groups are kept in memory until
+// the stream is consumed, using the encoded value of the grouped field as a key.
+type groupIterator struct {
+	st     document.Stream
+	path   FieldSelector
+	aggs   []aggregator
+	sorted bool
+	cfg    *database.TableConfig
+}
+
+type group struct {
+	value document.Value
+	accs  []accumulator
+}
+
+func (it groupIterator) newGroup(v document.Value) *group {
+	g := group{
+		value: v,
+		accs:  make([]accumulator, len(it.aggs)),
+	}
+
+	for i, a := range it.aggs {
+		g.accs[i] = a.newAccumulator()
+	}
+
+	return &g
+}
+
+func (g *group) add(stack EvalStack) error {
+	for _, acc := range g.accs {
+		err := acc.add(stack)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (it groupIterator) document(g *group) document.Document {
+	fb := document.NewFieldBuffer()
+
+	if it.path != nil {
+		// rebuild the documents along the path of the grouped field
+		v := g.value
+		for i := len(it.path) - 1; i > 0; i-- {
+			v = document.NewDocumentValue(document.NewFieldBuffer().Add(it.path[i], v))
+		}
+		fb.Add(it.path[0], v)
+	}
+
+	for i, a := range it.aggs {
+		fb.Add(a.String(), g.accs[i].value())
+	}
+
+	return fb
+}
+
+// groupKey returns the encoded value of v. Values that are equal
+// when compared together have the same key.
+func groupKey(v document.Value) (string, error) {
+	enc, err := index.EncodeFieldToIndexValue(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(append(enc, byte(index.NewTypeFromValueType(v.Type)))), nil
+}
+
+func (it groupIterator) Iterate(fn func(d document.Document) error) error {
+	if it.path == nil {
+		g := it.newGroup(nilLitteral)
+
+		err := it.st.Iterate(func(d document.Document) error {
+			return g.add(EvalStack{Document: d, Cfg: it.cfg})
+		})
+		if err != nil {
+			return err
+		}
+
+		return fn(it.document(g))
+	}
+
+	var cur *group
+	var curKey string
+	var groups []*group
+	var keys map[string]*group
+	if !it.sorted {
+		keys = make(map[string]*group)
+	}
+
+	err := it.st.Iterate(func(d document.Document) error {
+		stack := EvalStack{Document: d, Cfg: it.cfg}
+
+		v, err := evalPath(it.path, stack)
+		if err != nil {
+			return err
+		}
+
+		key, err := groupKey(v)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case it.sorted && (cur == nil || key != curKey):
+			if cur != nil {
+				err = fn(it.document(cur))
+				if err != nil {
+					return err
+				}
+			}
+
+			cur, curKey = it.newGroup(v), key
+		case !it.sorted:
+			var ok bool
+			cur, ok = keys[key]
+			if !ok {
+				cur = it.newGroup(v)
+				keys[key] = cur
+				groups = append(groups, cur)
+			}
+		}
+
+		return cur.add(stack)
+	})
+	if err != nil {
+		return err
+	}
+
+	if it.sorted {
+		if cur == nil {
+			return nil
+		}
+
+		return fn(it.document(cur))
+	}
+
+	for _, g := range groups {
+		err = fn(it.document(g))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexExtremaIterator returns a single group document containing the value of aggregators
+// that are all MIN or MAX functions on indexed fields. Instead of scanning the table,
+// the value of each aggregator is read from the document referenced by the first or last entry
+// of the index, ignoring null values.
+// Since values of different types are stored separately, in increasing type order,
+// MIN returns the smallest value of the first type found in the index and MAX the largest value
+// of the last one. If there are no such values, for example if the field only holds documents,
+// the table is scanned.
+type indexExtremaIterator struct {
+	tb      *database.Table
+	cfg     *database.TableConfig
+	indexes map[string]database.Index
+	aggs    []aggregator
+	stats   *Stats
+}
+
+// canReadExtremaFromIndexes returns true if every aggregator is a MIN or MAX function
+// on an indexed field.
+func canReadExtremaFromIndexes(aggs []aggregator, indexes map[string]database.Index) bool {
+	for _, a := range aggs {
+		path, _, ok := extremumPath(a)
+		if !ok {
+			return false
+		}
+
+		if _, ok := indexes[path.Name()]; !ok {
+			return false
+		}
+	}
+
+	return len(aggs) > 0
+}
+
+// extremumPath returns the path of a MIN or MAX aggregator and whether
+// it is a MAX aggregator.
+func extremumPath(a aggregator) (path FieldSelector, max bool, ok bool) {
+	switch t := a.(type) {
+	case MinFunc:
+		return t.Path, false, true
+	case MaxFunc:
+		return t.Path, true, true
+	}
+
+	return nil, false, false
+}
+
+// types of the values stored in non-null indexes, in index order.
+var extremumIndexTypes = []document.ValueType{
+	document.BoolValue,
+	document.Float64Value,
+	document.TextValue,
+	document.IPValue,
+	document.UUIDValue,
+	document.TimeValue,
+}
+
+func (it indexExtremaIterator) Iterate(fn func(d document.Document) error) error {
+	fb := document.NewFieldBuffer()
+
+	for _, a := range it.aggs {
+		v, err := it.extremum(a)
+		if err != nil {
+			return err
+		}
+
+		fb.Add(a.String(), v)
+	}
+
+	return fn(fb)
+}
+
+func (it indexExtremaIterator) extremum(a aggregator) (document.Value, error) {
+	path, max, _ := extremumPath(a)
+
+	var idx index.Index = it.indexes[path.Name()]
+	if it.stats != nil {
+		idx = statsIndex{Index: idx, stats: it.stats}
+	}
+
+	for i := range extremumIndexTypes {
+		var key []byte
+		var found bool
+		fn := func(val document.Value, k []byte) error {
+			key, found = k, true
+			return errStop
+		}
+
+		var err error
+		if max {
+			err = idx.DescendLessOrEqual(index.MaxValue(extremumIndexTypes[len(extremumIndexTypes)-1-i]), fn)
+		} else {
+			err = idx.AscendGreaterOrEqual(index.MinValue(extremumIndexTypes[i]), fn)
+		}
+		if err != nil && err != errStop {
+			return document.Value{}, err
+		}
+		if !found {
+			continue
+		}
+
+		d, err := it.tb.GetDocument(key)
+		if err != nil {
+			return document.Value{}, err
+		}
+		if it.stats != nil {
+			it.stats.TableReads++
+		}
+
+		return evalPath(path, EvalStack{Document: d, Cfg: it.cfg})
+	}
+
+	// the index only contains null values, documents or arrays
+	acc := a.newAccumulator()
+	err := it.tb.Iterate(func(d document.Document) error {
+		if it.stats != nil {
+			it.stats.TableReads++
+		}
+
+		return acc.add(EvalStack{Document: d, Cfg: it.cfg})
+	})
+	if err != nil {
+		return document.Value{}, err
+	}
+
+	return acc.value(), nil
+}
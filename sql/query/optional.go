@@ -0,0 +1,85 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/asdine/genji/document"
+)
+
+// The following functions create comparison expressions from optional parameters,
+// typically filters of an API that may or may not be provided.
+// The field is a dot separated path, such as "a.b.c", and v must be a pointer
+// to a value supported by document.NewValue.
+// If v is a nil pointer, they return a nil expression, which can be combined with other
+// expressions using AndIfPresent.
+// They return an error if v is not a pointer or if the value it points to is not supported.
+
+// EqIfPresent creates an expression that evaluates to the result of field = *v, or returns nil if v is nil.
+func EqIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Eq, field, v)
+}
+
+// NeqIfPresent creates an expression that evaluates to the result of field != *v, or returns nil if v is nil.
+func NeqIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Neq, field, v)
+}
+
+// GtIfPresent creates an expression that evaluates to the result of field > *v, or returns nil if v is nil.
+func GtIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Gt, field, v)
+}
+
+// GteIfPresent creates an expression that evaluates to the result of field >= *v, or returns nil if v is nil.
+func GteIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Gte, field, v)
+}
+
+// LtIfPresent creates an expression that evaluates to the result of field < *v, or returns nil if v is nil.
+func LtIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Lt, field, v)
+}
+
+// LteIfPresent creates an expression that evaluates to the result of field <= *v, or returns nil if v is nil.
+func LteIfPresent(field string, v interface{}) (Expr, error) {
+	return cmpIfPresent(Lte, field, v)
+}
+
+func cmpIfPresent(op func(a, b Expr) CmpOp, field string, v interface{}) (Expr, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("expected a pointer, got %T", v)
+	}
+	if rv.IsNil() {
+		return nil, nil
+	}
+
+	val, err := document.NewValue(rv.Elem().Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return op(FieldSelector(strings.Split(field, ".")), LiteralValue(val)), nil
+}
+
+// AndIfPresent combines the non nil expressions with the And operator.
+// It returns nil if all the expressions are nil, which selects all the documents
+// when used as a where clause.
+func AndIfPresent(exprs ...Expr) Expr {
+	var e Expr
+
+	for _, ex := range exprs {
+		if ex == nil {
+			continue
+		}
+
+		if e == nil {
+			e = ex
+		} else {
+			e = And(e, ex)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,72 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfPresent(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test;
+		INSERT INTO test (k, color, size) VALUES (1, 'red', 10);
+		INSERT INTO test (k, color, size) VALUES (2, 'blue', 10);
+		INSERT INTO test (k, color, size) VALUES (3, 'red', 20)`)
+	require.NoError(t, err)
+
+	strPtr := func(s string) *string { return &s }
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name     string
+		color    *string
+		minSize  *int
+		expected string
+	}{
+		{"none", nil, nil, `[{"k": 1}, {"k": 2}, {"k": 3}]`},
+		{"color", strPtr("red"), nil, `[{"k": 1}, {"k": 3}]`},
+		{"size", nil, intPtr(15), `[{"k": 3}]`},
+		{"both", strPtr("blue"), intPtr(15), `[]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			color, err := query.EqIfPresent("color", test.color)
+			require.NoError(t, err)
+			size, err := query.GteIfPresent("size", test.minSize)
+			require.NoError(t, err)
+
+			stmt := query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"k"}), ExprName: "k"}},
+				WhereExpr: query.AndIfPresent(color, size),
+			}
+
+			res, err := query.New(stmt).Run(db.DB, nil)
+			require.NoError(t, err)
+			defer res.Close()
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, res)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+
+	t.Run("Should fail if v is not a pointer", func(t *testing.T) {
+		_, err := query.EqIfPresent("a", 10)
+		require.Error(t, err)
+	})
+
+	t.Run("Should fail if v points to an unsupported value", func(t *testing.T) {
+		_, err := query.EqIfPresent("a", new(chan int))
+		require.Error(t, err)
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"container/heap"
 	"database/sql/driver"
 	"errors"
+	"io"
+	"sort"
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
@@ -26,6 +28,22 @@ type queryPlanField struct {
 	e            Expr
 	uniqueIndex  bool
 	isPrimaryKey bool
+
+	// if mergeOp is AND or OR, the keys selected by the indexes
+	// of both fields are intersected or merged respectively.
+	mergeOp     scanner.Token
+	left, right *queryPlanField
+}
+
+// bounded reports whether the keys selected by f are limited to the documents
+// matching an equality, as opposed to a range which may select most of the index.
+// Merging keys requires holding them in memory, so only bounded fields are merged.
+func (f *queryPlanField) bounded() bool {
+	if f.mergeOp != 0 {
+		return f.left.bounded() && f.right.bounded()
+	}
+
+	return f.op == scanner.EQ
 }
 
 func newQueryOptimizer(tx *database.Transaction, tableName string) (qo queryOptimizer, err error) {
@@ -44,12 +62,16 @@ func newQueryOptimizer(tx *database.Transaction, tableName string) (qo queryOpti
 		return
 	}
 
+	opts := tx.DB().Options()
+
 	return queryOptimizer{
-		tx:        tx,
-		t:         t,
-		tableName: tableName,
-		cfg:       cfg,
-		indexes:   indexes,
+		tx:             tx,
+		t:              t,
+		tableName:      tableName,
+		cfg:            cfg,
+		indexes:        indexes,
+		sortBufferSize: opts.SortBufferSize,
+		sortTempDir:    opts.SortTempDir,
 	}, nil
 }
 
@@ -66,6 +88,16 @@ type queryOptimizer struct {
 	orderByDirection scanner.Token
 	limit            int
 	offset           int
+	stats            *Stats
+
+	// sortBufferSize and sortTempDir control how sortIterator
+	// spills to temporary files, see database.Options.
+	sortBufferSize int
+	sortTempDir    string
+
+	// closers must be closed once the stream returned by optimizeQuery
+	// or sortIterator is not used anymore.
+	closers []io.Closer
 }
 
 func (qo *queryOptimizer) optimizeQuery() (st document.Stream, err error) {
@@ -114,14 +146,13 @@ func (qo *queryOptimizer) optimizeQuery() (st document.Stream, err error) {
 			evalValue:        v,
 		})
 	default:
-		st = document.NewStream(indexIterator{
-			tx:               qo.tx,
-			tb:               qo.t,
-			args:             qo.args,
-			op:               qp.field.op,
-			e:                qp.field.e,
-			index:            qo.indexes[qp.field.indexedField.Name()],
-			orderByDirection: qo.orderByDirection,
+		st = document.NewStream(qo.newKeyIterator(qp.field))
+	}
+
+	if qo.stats != nil {
+		st = st.Map(func(d document.Document) (document.Document, error) {
+			qo.stats.TableReads++
+			return d, nil
 		})
 	}
 
@@ -130,6 +161,13 @@ func (qo *queryOptimizer) optimizeQuery() (st document.Stream, err error) {
 		Params: qo.args,
 	}))
 
+	if qo.stats != nil {
+		st = st.Map(func(d document.Document) (document.Document, error) {
+			qo.stats.DocumentsMatched++
+			return d, nil
+		})
+	}
+
 	if len(qo.orderBy) != 0 && !qp.sorted {
 		st, err = qo.sortIterator(st)
 	}
@@ -148,7 +186,7 @@ func (qo *queryOptimizer) buildQueryPlan() queryPlan {
 			if ok || (pk != nil && pk.Path.String() == qo.orderBy.Name()) {
 				qp.field = &queryPlanField{
 					indexedField: qo.orderBy,
-					isPrimaryKey: pk.Path.String() == qo.orderBy.Name(),
+					isPrimaryKey: pk != nil && pk.Path.String() == qo.orderBy.Name(),
 				}
 				qp.sorted = true
 
@@ -165,7 +203,10 @@ func (qo *queryOptimizer) buildQueryPlan() queryPlan {
 // analyseExpr is a recursive function that scans each node the e Expr tree.
 // If it contains a comparison operator, it checks if this operator and its operands
 // can benefit from using an index. This check is done in the cmpOpCanUseIndex function.
-// If it contains an AND operator it checks if one of the operands can use an index.
+// If it contains an AND operator it checks if one of the operands can use an index,
+// or if the keys selected by the indexes of both operands can be intersected.
+// If it contains an OR operator, both operands must use an index and their keys are merged.
+// Keys are only intersected or merged if both operands are bounded, see queryPlanField.bounded.
 func (qo *queryOptimizer) analyseExpr(e Expr) *queryPlanField {
 	switch t := e.(type) {
 	case CmpOp:
@@ -199,7 +240,7 @@ func (qo *queryOptimizer) analyseExpr(e Expr) *queryPlanField {
 
 	case *AndOp:
 		nodeL := qo.analyseExpr(t.LeftHand())
-		nodeR := qo.analyseExpr(t.LeftHand())
+		nodeR := qo.analyseExpr(t.RightHand())
 
 		if nodeL == nil && nodeR == nil {
 			return nil
@@ -213,12 +254,75 @@ func (qo *queryOptimizer) analyseExpr(e Expr) *queryPlanField {
 			return nodeR
 		}
 
+		// if both operands use a non unique index with an equality, reading the keys of both indexes
+		// and only fetching the documents selected by both of them is cheaper
+		// than fetching all the documents selected by one of them.
+		if nodeL != nil && nodeR != nil && !nodeL.isPrimaryKey && !nodeR.isPrimaryKey &&
+			nodeL.bounded() && nodeR.bounded() {
+			return &queryPlanField{
+				mergeOp: scanner.AND,
+				left:    nodeL,
+				right:   nodeR,
+			}
+		}
+
+		// otherwise only one index is used, preferably with an equality,
+		// and the other operand is evaluated by the where clause.
+		if nodeL == nil || (nodeR != nil && nodeR.bounded() && !nodeL.bounded()) {
+			return nodeR
+		}
+
 		return nodeL
+
+	case *OrOp:
+		nodeL := qo.analyseExpr(t.LeftHand())
+		nodeR := qo.analyseExpr(t.RightHand())
+
+		// both operands must use an index with an equality, otherwise the table must be scanned anyway
+		// or the merged keys could hold most of the table in memory.
+		if nodeL == nil || nodeR == nil || nodeL.isPrimaryKey || nodeR.isPrimaryKey ||
+			!nodeL.bounded() || !nodeR.bounded() {
+			return nil
+		}
+
+		return &queryPlanField{
+			mergeOp: scanner.OR,
+			left:    nodeL,
+			right:   nodeR,
+		}
 	}
 
 	return nil
 }
 
+// newKeyIterator returns an iterator that selects the keys of the documents
+// using the index of the field, or merging the keys selected by other iterators.
+func (qo *queryOptimizer) newKeyIterator(f *queryPlanField) keyIterator {
+	if f.mergeOp != 0 {
+		return indexMergeIterator{
+			tb:    qo.t,
+			op:    f.mergeOp,
+			left:  qo.newKeyIterator(f.left),
+			right: qo.newKeyIterator(f.right),
+		}
+	}
+
+	var idx index.Index = qo.indexes[f.indexedField.Name()]
+	if qo.stats != nil {
+		idx = statsIndex{Index: idx, stats: qo.stats}
+	}
+
+	return indexIterator{
+		tx:               qo.tx,
+		tb:               qo.t,
+		args:             qo.args,
+		op:               f.op,
+		e:                f.e,
+		index:            idx,
+		orderByDirection: qo.orderByDirection,
+	}
+}
+
 func cmpOpCanUseIndex(cmp *CmpOp) (bool, FieldSelector, Expr) {
 	switch cmp.Token {
 	case scanner.EQ, scanner.GT, scanner.GTE, scanner.LT, scanner.LTE:
@@ -253,6 +357,14 @@ func evaluatesToScalarOrParam(e Expr) bool {
 	return false
 }
 
+// keyIterator is a document iterator that can also iterate over
+// the keys of the documents it selects, without fetching them.
+type keyIterator interface {
+	document.Iterator
+
+	iterateKeys(fn func(key []byte) error) error
+}
+
 type indexIterator struct {
 	tx               *database.Transaction
 	tb               *database.Table
@@ -265,27 +377,17 @@ type indexIterator struct {
 
 var errStop = errors.New("stop")
 
-func (it indexIterator) Iterate(fn func(d document.Document) error) error {
+func (it indexIterator) iterateKeys(fn func(key []byte) error) error {
 	if it.e == nil {
 		var err error
 
 		if it.orderByDirection == scanner.DESC {
 			err = it.index.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
-
-				return fn(r)
+				return fn(key)
 			})
 		} else {
 			err = it.index.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
-
-				return fn(r)
+				return fn(key)
 			})
 		}
 
@@ -300,13 +402,6 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 		return err
 	}
 
-	if v.Type.IsNumber() {
-		v, err = v.ConvertTo(document.Float64Value)
-		if err != nil {
-			return err
-		}
-	}
-
 	switch it.op {
 	case scanner.EQ:
 		err = it.index.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
@@ -316,12 +411,7 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 			}
 
 			if ok {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
-
-				return fn(r)
+				return fn(key)
 			}
 
 			return errStop
@@ -337,24 +427,14 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 				return nil
 			}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
-
-			return fn(r)
+			return fn(key)
 		})
 	case scanner.GTE:
 		err = it.index.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
-
-			return fn(r)
+			return fn(key)
 		})
 	case scanner.LT:
-		err = it.index.AscendGreaterOrEqual(index.EmptyPivot(v.Type), func(val document.Value, key []byte) error {
+		err = it.index.AscendGreaterOrEqual(index.MinValue(v.Type), func(val document.Value, key []byte) error {
 			ok, err := v.IsLesserThanOrEqual(val)
 			if err != nil {
 				return err
@@ -364,15 +444,10 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 				return errStop
 			}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
-
-			return fn(r)
+			return fn(key)
 		})
 	case scanner.LTE:
-		err = it.index.AscendGreaterOrEqual(index.EmptyPivot(v.Type), func(val document.Value, key []byte) error {
+		err = it.index.AscendGreaterOrEqual(index.MinValue(v.Type), func(val document.Value, key []byte) error {
 			ok, err := v.IsLesserThan(val)
 			if err != nil {
 				return err
@@ -382,12 +457,7 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 				return errStop
 			}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
-
-			return fn(r)
+			return fn(key)
 		})
 	}
 
@@ -398,6 +468,92 @@ func (it indexIterator) Iterate(fn func(d document.Document) error) error {
 	return nil
 }
 
+// Reverse returns an iterator that reads the whole index in the opposite order,
+// or nil if the iterator is restricted to a range of the index.
+func (it indexIterator) Reverse() document.Iterator {
+	if it.e != nil {
+		return nil
+	}
+
+	it.orderByDirection = reverseDirection(it.orderByDirection)
+	return it
+}
+
+func (it indexIterator) Iterate(fn func(d document.Document) error) error {
+	return it.iterateKeys(func(key []byte) error {
+		r, err := it.tb.GetDocument(key)
+		if err != nil {
+			return err
+		}
+
+		return fn(r)
+	})
+}
+
+// indexMergeIterator selects the keys selected by both iterators, if op is AND,
+// or by any of them, if op is OR, and fetches the associated documents in key order.
+type indexMergeIterator struct {
+	tb          *database.Table
+	op          scanner.Token
+	left, right keyIterator
+}
+
+func (it indexMergeIterator) iterateKeys(fn func(key []byte) error) error {
+	selected := make(map[string]bool)
+	err := it.left.iterateKeys(func(key []byte) error {
+		selected[string(key)] = it.op == scanner.OR
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if it.op == scanner.OR {
+		err = it.right.iterateKeys(func(key []byte) error {
+			selected[string(key)] = true
+			return nil
+		})
+	} else {
+		err = it.right.iterateKeys(func(key []byte) error {
+			if _, ok := selected[string(key)]; ok {
+				selected[string(key)] = true
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(selected))
+	for k, ok := range selected {
+		if ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		err = fn([]byte(k))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (it indexMergeIterator) Iterate(fn func(d document.Document) error) error {
+	return it.iterateKeys(func(key []byte) error {
+		r, err := it.tb.GetDocument(key)
+		if err != nil {
+			return err
+		}
+
+		return fn(r)
+	})
+}
+
 type pkIterator struct {
 	tx               *database.Transaction
 	tb               *database.Table
@@ -409,6 +565,25 @@ type pkIterator struct {
 	evalValue        document.Value
 }
 
+// Reverse returns an iterator that reads the whole table in the opposite order,
+// or nil if the iterator is restricted to a range of primary keys.
+func (it pkIterator) Reverse() document.Iterator {
+	if it.e != nil {
+		return nil
+	}
+
+	it.orderByDirection = reverseDirection(it.orderByDirection)
+	return it
+}
+
+func reverseDirection(direction scanner.Token) scanner.Token {
+	if direction == scanner.DESC {
+		return scanner.ASC
+	}
+
+	return scanner.DESC
+}
+
 func (it pkIterator) Iterate(fn func(d document.Document) error) error {
 	if it.e == nil {
 		var err error
@@ -488,8 +663,9 @@ func (it pkIterator) Iterate(fn func(d document.Document) error) error {
 // Once the heap is filled entirely with the content of the table a stream is returned.
 // During iteration, the stream will pop the k-smallest or k-largest elements, depending on
 // the chosen sorting order (ASC or DESC).
-// This function is not memory efficient as it's loading the entire table in memory before
-// returning the k-smallest or k-largest elements.
+// To bound memory usage, whenever the content of the heap exceeds sortBufferSize bytes,
+// the k first elements of the heap are written in order to a temporary file as a sorted run and the heap
+// is emptied. During iteration, the runs and the remaining content of the heap are then merged together.
 func (qo *queryOptimizer) sortIterator(it document.Iterator) (st document.Stream, err error) {
 	k := 0
 	if qo.limit != -1 {
@@ -510,6 +686,9 @@ func (qo *queryOptimizer) sortIterator(it document.Iterator) (st document.Stream
 
 	heap.Init(h)
 
+	var runs *sortRuns
+	var size int
+
 	err = it.Iterate(func(d document.Document) error {
 		v, err := path.GetValue(d)
 		if err != nil && err != document.ErrFieldNotFound {
@@ -534,13 +713,43 @@ func (qo *queryOptimizer) sortIterator(it document.Iterator) (st document.Stream
 			data:  data,
 		})
 
-		return nil
+		size += len(value) + len(data)
+		if qo.sortBufferSize <= 0 || size < qo.sortBufferSize {
+			return nil
+		}
+
+		if runs == nil {
+			runs, err = newSortRuns(qo.sortTempDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		size = 0
+		return runs.spill(h, k)
 	})
 	if err != nil {
+		if runs != nil {
+			runs.Close()
+		}
 		return
 	}
 
-	st = document.NewStream(&sortedIterator{h, k})
+	if runs == nil {
+		st = document.NewStream(&sortedIterator{h, k})
+		return
+	}
+
+	// the runs are removed once iterated over, but the stream
+	// might never be iterated over
+	qo.closers = append(qo.closers, runs)
+
+	st = document.NewStream(&mergeSortedIterator{
+		h:    h,
+		runs: runs,
+		k:    k,
+		desc: qo.orderByDirection == scanner.DESC,
+	})
 
 	return
 }
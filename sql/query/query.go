@@ -3,6 +3,7 @@ package query
 import (
 	"database/sql/driver"
 	"errors"
+	"io"
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
@@ -16,6 +17,13 @@ var ErrResultClosed = errors.New("result already closed")
 // Results are returned as streams.
 type Query struct {
 	Statements []Statement
+
+	// Stats, if not nil, collects runtime statistics about the execution of the statements.
+	// They are complete once the result has been consumed.
+	Stats *Stats
+
+	// OnClose, if not nil, is called once the result returned by Run or Exec has been closed.
+	OnClose func()
 }
 
 // Run executes all the statements in their own transaction and returns the last result.
@@ -25,6 +33,15 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 	var err error
 
 	for _, stmt := range q.Statements {
+		// the result of the previous statement is discarded
+		err = closeAll(res.closers)
+		if err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return nil, err
+		}
+
 		// it there is an opened transaction but there are still statements
 		// to be executed, close the current transaction.
 		if tx != nil {
@@ -47,6 +64,10 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 			return nil, err
 		}
 
+		if q.Stats != nil {
+			stmt = withStats(stmt, q.Stats)
+		}
+
 		res, err = stmt.Run(tx, args)
 		if err != nil {
 			tx.Rollback()
@@ -57,6 +78,7 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 	// the returned result will now own the transaction.
 	// its Close method is expected to be called.
 	res.tx = tx
+	res.onClose = q.OnClose
 
 	return &res, nil
 }
@@ -68,6 +90,12 @@ func (q Query) Exec(tx *database.Transaction, args []driver.NamedValue, forceRea
 	var err error
 
 	for _, stmt := range q.Statements {
+		// the result of the previous statement is discarded
+		err = closeAll(res.closers)
+		if err != nil {
+			return nil, err
+		}
+
 		// if the statement requires a writable transaction,
 		// promote the current transaction.
 		if !forceReadOnly && !tx.Writable() && !stmt.IsReadOnly() {
@@ -77,12 +105,18 @@ func (q Query) Exec(tx *database.Transaction, args []driver.NamedValue, forceRea
 			}
 		}
 
+		if q.Stats != nil {
+			stmt = withStats(stmt, q.Stats)
+		}
+
 		res, err = stmt.Run(tx, args)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	res.onClose = q.OnClose
+
 	return &res, nil
 }
 
@@ -104,6 +138,10 @@ type Result struct {
 	lastInsertKey []byte
 	tx            *database.Transaction
 	closed        bool
+	onClose       func()
+
+	// closers release the resources used by the stream, like temporary files.
+	closers []io.Closer
 }
 
 // LastInsertId is not supported and returns an error.
@@ -140,11 +178,33 @@ func (r *Result) Close() (err error) {
 
 	r.closed = true
 
+	err = closeAll(r.closers)
+
 	if r.tx != nil {
+		var txErr error
 		if r.tx.Writable() {
-			err = r.tx.Commit()
+			txErr = r.tx.Commit()
 		} else {
-			err = r.tx.Rollback()
+			txErr = r.tx.Rollback()
+		}
+		if err == nil {
+			err = txErr
+		}
+	}
+
+	if r.onClose != nil {
+		r.onClose()
+	}
+
+	return err
+}
+
+// closeAll closes all the closers and returns the first error encountered.
+func closeAll(closers []io.Closer) error {
+	var err error
+	for _, c := range closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
 		}
 	}
 
@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
@@ -14,11 +16,15 @@ import (
 type SelectStmt struct {
 	TableName        string
 	WhereExpr        Expr
+	GroupBy          FieldSelector
+	HavingExpr       Expr
 	OrderBy          FieldSelector
 	OrderByDirection scanner.Token
 	OffsetExpr       Expr
 	LimitExpr        Expr
 	Selectors        []ResultField
+
+	stats *Stats
 }
 
 // IsReadOnly always returns true. It implements the Statement interface.
@@ -104,10 +110,16 @@ func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue)
 		limit = int(vlim)
 	}
 
+	var start time.Time
+	if stmt.stats != nil {
+		start = time.Now()
+	}
+
 	qo, err := newQueryOptimizer(tx, stmt.TableName)
 	if err != nil {
 		return res, err
 	}
+	qo.stats = stmt.stats
 	qo.whereExpr = stmt.WhereExpr
 	qo.args = args
 	qo.orderBy = stmt.OrderBy
@@ -115,17 +127,68 @@ func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue)
 	qo.limit = limit
 	qo.offset = offset
 
-	st, err := qo.optimizeQuery()
-	if err != nil {
-		return res, err
+	var aggs []aggregator
+	for _, rf := range stmt.Selectors {
+		if e, ok := rf.(ResultFieldExpr); ok {
+			aggs = collectAggregators(e.Expr, aggs)
+		}
+	}
+	if stmt.HavingExpr != nil {
+		aggs = collectAggregators(stmt.HavingExpr, aggs)
 	}
 
-	if offset > 0 {
-		st = st.Offset(offset)
+	grouped := len(stmt.GroupBy) != 0 || len(aggs) != 0 || stmt.HavingExpr != nil
+	if grouped {
+		// the order, offset and limit are applied to the groups
+		qo.orderBy = nil
+		qo.limit = -1
+		qo.offset = -1
+
+		// if the grouped field is indexed, read the documents in order
+		// so that groups can be computed one at a time
+		if _, ok := qo.indexes[stmt.GroupBy.Name()]; ok && len(stmt.GroupBy) != 0 {
+			qo.orderBy = stmt.GroupBy
+			qo.orderByDirection = scanner.ASC
+		}
 	}
 
-	if limit >= 0 {
-		st = st.Limit(limit)
+	// the extrema of indexed fields are read from their indexes instead of scanning the table
+	extrema := grouped && len(stmt.GroupBy) == 0 && stmt.WhereExpr == nil && canReadExtremaFromIndexes(aggs, qo.indexes)
+
+	var st document.Stream
+	if extrema {
+		st = document.NewStream(indexExtremaIterator{
+			tb:      qo.t,
+			cfg:     qo.cfg,
+			indexes: qo.indexes,
+			aggs:    aggs,
+			stats:   stmt.stats,
+		})
+	} else {
+		st, err = qo.optimizeQuery()
+		if err != nil {
+			return res, err
+		}
+	}
+
+	if grouped && !extrema {
+		st = document.NewStream(groupIterator{
+			st:     st,
+			path:   stmt.GroupBy,
+			aggs:   aggs,
+			sorted: len(qo.orderBy) != 0,
+			cfg:    qo.cfg,
+		})
+	}
+
+	if !grouped {
+		if offset > 0 {
+			st = st.Offset(offset)
+		}
+
+		if limit >= 0 {
+			st = st.Limit(limit)
+		}
 	}
 
 	st = st.Map(func(d document.Document) (document.Document, error) {
@@ -136,7 +199,70 @@ func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue)
 		}, nil
 	})
 
-	return Result{Stream: st}, nil
+	closers := qo.closers
+	if grouped {
+		// the having clause is evaluated against the result documents,
+		// before the order, offset and limit
+		having := whereClause(stmt.HavingExpr, EvalStack{
+			Tx:     tx,
+			Params: args,
+			Cfg:    qo.cfg,
+		})
+		st = st.Filter(func(d document.Document) (bool, error) {
+			return having(havingDocument{d.(documentMask)})
+		})
+
+		var cs []io.Closer
+		st, cs, err = groupedResult(tx, st, stmt.OrderBy, stmt.OrderByDirection, offset, limit)
+		closers = append(closers, cs...)
+		if err != nil {
+			closeAll(closers)
+			return res, err
+		}
+	}
+
+	if stmt.stats != nil {
+		stmt.stats.Elapsed += time.Since(start)
+		st = document.NewStream(statsIterator{it: st, stats: stmt.stats})
+	}
+
+	return Result{Stream: st, closers: closers}, nil
+}
+
+// groupedResult sorts the results of a grouped query by the given field of the result documents,
+// then applies the offset and the limit.
+// The returned closers must be closed once the stream is not used anymore.
+func groupedResult(tx *database.Transaction, st document.Stream, orderBy FieldSelector, direction scanner.Token, offset, limit int) (document.Stream, []io.Closer, error) {
+	var closers []io.Closer
+
+	if len(orderBy) != 0 {
+		opts := tx.DB().Options()
+		qo := queryOptimizer{
+			orderBy:          orderBy,
+			orderByDirection: direction,
+			offset:           offset,
+			limit:            limit,
+			sortBufferSize:   opts.SortBufferSize,
+			sortTempDir:      opts.SortTempDir,
+		}
+
+		var err error
+		st, err = qo.sortIterator(st)
+		if err != nil {
+			return st, nil, err
+		}
+		closers = qo.closers
+	}
+
+	if offset > 0 {
+		st = st.Offset(offset)
+	}
+
+	if limit >= 0 {
+		st = st.Limit(limit)
+	}
+
+	return st, closers, nil
 }
 
 type documentMask struct {
@@ -147,10 +273,46 @@ type documentMask struct {
 
 var _ document.Document = documentMask{}
 
-func (r documentMask) GetByField(name string) (document.Value, error) {
+// GetByField returns the value of the first result field named name,
+// so that fields renamed with AS or computed by an expression can be looked up.
+// Only the expression of that field is evaluated.
+func (r documentMask) GetByField(name string) (v document.Value, err error) {
 	for _, rf := range r.resultFields {
-		if rf.Name() == name || rf.Name() == "*" {
-			return r.r.GetByField(name)
+		switch t := rf.(type) {
+		case ResultFieldExpr:
+			if t.ExprName != name {
+				continue
+			}
+
+			return t.lookup(EvalStack{
+				Document: r.r,
+				Cfg:      r.cfg,
+			})
+		case Wildcard:
+			if r.r == nil {
+				break
+			}
+
+			v, err := r.r.GetByField(name)
+			if err == document.ErrFieldNotFound {
+				continue
+			}
+			return v, err
+		}
+
+		err = rf.Iterate(EvalStack{Document: r.r, Cfg: r.cfg}, func(f string, fv document.Value) error {
+			if f == name {
+				v = fv
+				return errStop
+			}
+
+			return nil
+		})
+		if err == errStop {
+			return v, nil
+		}
+		if err != nil {
+			return document.Value{}, err
 		}
 	}
 
@@ -173,6 +335,23 @@ func (r documentMask) Iterate(fn func(f string, v document.Value) error) error {
 	return nil
 }
 
+// havingDocument is the document the HAVING clause is evaluated against.
+// Its fields are the ones of the result document, so that fields renamed with AS
+// can be used, and then the ones of the group document, which holds the grouped field
+// and the value of every aggregator, selected or not.
+type havingDocument struct {
+	documentMask
+}
+
+func (h havingDocument) GetByField(name string) (document.Value, error) {
+	v, err := h.documentMask.GetByField(name)
+	if err == document.ErrFieldNotFound {
+		return h.documentMask.r.GetByField(name)
+	}
+
+	return v, err
+}
+
 // A ResultField is a field that will be part of the result document that will be returned at the end of a Select statement.
 type ResultField interface {
 	Iterate(stack EvalStack, fn func(field string, value document.Value) error) error
@@ -193,14 +372,24 @@ func (r ResultFieldExpr) Name() string {
 
 // Iterate evaluates Expr and calls fn once with the result.
 func (r ResultFieldExpr) Iterate(stack EvalStack, fn func(field string, value document.Value) error) error {
-	v, err := r.Expr.Eval(stack)
-	if err != nil && err != document.ErrFieldNotFound {
+	v, err := r.lookup(stack)
+	if err != nil {
 		return err
 	}
 
 	return fn(r.ExprName, v)
 }
 
+// lookup evaluates Expr. Fields that don't exist are not considered as errors.
+func (r ResultFieldExpr) lookup(stack EvalStack) (document.Value, error) {
+	v, err := r.Expr.Eval(stack)
+	if err != nil && err != document.ErrFieldNotFound {
+		return document.Value{}, err
+	}
+
+	return v, nil
+}
+
 // A Wildcard is a ResultField that iterates over all the fields of a document.
 type Wildcard struct{}
 
@@ -209,6 +398,12 @@ func (w Wildcard) Name() string {
 	return "*"
 }
 
+// Eval returns an error, a wildcard can only be used as a result field
+// or as the argument of COUNT. It implements the Expr interface.
+func (w Wildcard) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("* can only be used as a result field or in COUNT(*)")
+}
+
 // Iterate call the document iterate method.
 func (w Wildcard) Iterate(stack EvalStack, fn func(fd string, v document.Value) error) error {
 	if stack.Document == nil {
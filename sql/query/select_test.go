@@ -3,10 +3,17 @@ package query_test
 import (
 	"bytes"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/parser"
+	"github.com/asdine/genji/sql/query"
+	"github.com/asdine/genji/sql/scanner"
 	"github.com/stretchr/testify/require"
 )
 
@@ -172,6 +179,85 @@ func TestSelectStmt(t *testing.T) {
 		call("SELECT a.b FROM test", `{"a.b": 1}`, `{"a.b": null}`, `{"a.b": null}`)
 		call("SELECT a.1 FROM test", `{"a.1": null}`, `{"a.1": null}`, `{"a.1": 2}`)
 		call("SELECT a.2.1 FROM test", `{"a.2.1": null}`, `{"a.2.1": null}`, `{"a.2.1": 9}`)
+
+		err = db.Exec(`CREATE TABLE docs; INSERT INTO docs VALUES {a: {b: 2, a: 1}}, {a: {a: 1}}, {a: {a: 0, c: 5}}, {a: {a: 1, b: 1}}`)
+		require.NoError(t, err)
+		call("SELECT a FROM docs ORDER BY a", `{"a": {"a": 0, "c": 5}}`, `{"a": {"a": 1}}`, `{"a": {"a": 1, "b": 1}}`, `{"a": {"b": 2, "a": 1}}`)
+		call("SELECT a FROM docs ORDER BY a DESC LIMIT 1", `{"a": {"b": 2, "a": 1}}`)
+	})
+
+	t.Run("with values of incompatible types", func(t *testing.T) {
+		for _, indexed := range []bool{false, true} {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec("CREATE TABLE test")
+			require.NoError(t, err)
+			if indexed {
+				err = db.Exec("CREATE INDEX idx_a ON test (a)")
+				require.NoError(t, err)
+			}
+
+			err = db.Exec("INSERT INTO test (a) VALUES ('foo'); INSERT INTO test (a) VALUES (1)")
+			require.NoError(t, err)
+
+			// values of incompatible types are considered different
+			for query, expected := range map[string]string{
+				"SELECT a FROM test WHERE a = 1":  `[{"a": 1}]`,
+				"SELECT a FROM test WHERE a != 1": `[{"a": "foo"}]`,
+			} {
+				st, err := db.Query(query)
+				require.NoError(t, err)
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err, "indexed: %v", indexed)
+				require.JSONEq(t, expected, buf.String(), "%s, indexed: %v", query, indexed)
+				require.NoError(t, st.Close())
+			}
+		}
+	})
+
+	t.Run("with integers that can't be represented by a float64", func(t *testing.T) {
+		for _, indexed := range []bool{false, true} {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec("CREATE TABLE tt")
+			require.NoError(t, err)
+			if indexed {
+				err = db.Exec("CREATE INDEX idx_tt_a ON tt(a)")
+				require.NoError(t, err)
+			}
+
+			err = db.Exec(`INSERT INTO tt (a) VALUES (9007199254740992), (9007199254740993)`)
+			require.NoError(t, err)
+
+			call := func(q string, expected ...int64) {
+				st, err := db.Query(q)
+				require.NoError(t, err)
+				defer st.Close()
+
+				var res []int64
+				err = st.Iterate(func(d document.Document) error {
+					var a int64
+					err := document.Scan(d, &a)
+					res = append(res, a)
+					return err
+				})
+				require.NoError(t, err)
+				require.Equal(t, expected, res, "indexed: %v, query: %s", indexed, q)
+			}
+
+			call("SELECT a FROM tt WHERE a > 9007199254740992", 9007199254740993)
+			call("SELECT a FROM tt WHERE a >= 9007199254740993", 9007199254740993)
+			call("SELECT a FROM tt WHERE a = 9007199254740993", 9007199254740993)
+			call("SELECT a FROM tt WHERE a < 9007199254740993", 9007199254740992)
+			call("SELECT a FROM tt WHERE a <= 9007199254740992", 9007199254740992)
+			call("SELECT a FROM tt ORDER BY a DESC", 9007199254740993, 9007199254740992)
+		}
 	})
 
 	t.Run("table not found", func(t *testing.T) {
@@ -183,3 +269,549 @@ func TestSelectStmt(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestSelectStmtOrderByIndexWithoutPrimaryKey(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test; CREATE INDEX idx_a ON test (a)")
+	require.NoError(t, err)
+
+	err = db.Exec("INSERT INTO test (a) VALUES (2); INSERT INTO test (a) VALUES (1); INSERT INTO test (a) VALUES (3)")
+	require.NoError(t, err)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT a FROM test ORDER BY a", `[{"a": 1}, {"a": 2}, {"a": 3}]`},
+		{"SELECT a FROM test ORDER BY a DESC", `[{"a": 3}, {"a": 2}, {"a": 1}]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			st, err := db.Query(test.query)
+			require.NoError(t, err)
+			defer st.Close()
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestSelectStmtResultGetByField(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test; INSERT INTO test (a, b) VALUES (1, 'foo')")
+	require.NoError(t, err)
+
+	st, err := db.Query("SELECT a AS x, a + 1, b FROM test")
+	require.NoError(t, err)
+	defer st.Close()
+
+	d, err := st.First()
+	require.NoError(t, err)
+
+	tests := []struct {
+		field    string
+		expected document.Value
+	}{
+		{"x", document.NewInt8Value(1)},
+		{"a + 1", document.NewInt64Value(2)},
+		{"b", document.NewTextValue("foo")},
+	}
+
+	for _, test := range tests {
+		v, err := d.GetByField(test.field)
+		require.NoError(t, err)
+		ok, err := v.IsEqual(test.expected)
+		require.NoError(t, err)
+		require.True(t, ok, "field %q: got %v", test.field, v)
+	}
+
+	// fields that are not part of the result are not returned
+	_, err = d.GetByField("a")
+	require.Equal(t, document.ErrFieldNotFound, err)
+}
+
+func TestSelectStmtSortWithTempFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := genji.OpenWithOptions(":memory:", genji.Options{
+		SortBufferSize: 100,
+		SortTempDir:    dir,
+	})
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = db.Exec("INSERT INTO test (a, b) VALUES (?, ?)", (i*37)%100, "some text to fill the buffer")
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"SELECT a FROM test ORDER BY a", seq(0, 100, 1)},
+		{"SELECT a FROM test ORDER BY a DESC", seq(99, -1, -1)},
+		{"SELECT a FROM test ORDER BY a LIMIT 10", seq(0, 10, 1)},
+		{"SELECT a FROM test ORDER BY a DESC LIMIT 10 OFFSET 5", seq(94, 84, -1)},
+		{"SELECT a FROM test WHERE a >= 50 ORDER BY a", seq(50, 100, 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			res, err := db.Query(test.query)
+			require.NoError(t, err)
+			defer res.Close()
+
+			var got []int
+			err = res.Iterate(func(d document.Document) error {
+				var a int
+				err := document.Scan(d, &a)
+				got = append(got, a)
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, got)
+
+			// temporary files must be removed after iteration
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			require.Empty(t, files)
+		})
+	}
+
+	t.Run("closed without being read", func(t *testing.T) {
+		tests := []struct {
+			query string
+			// the result of the first statement is discarded
+			// when the query contains more than one statement
+			spilled bool
+		}{
+			{"SELECT a FROM test ORDER BY a", true},
+			{"SELECT a, COUNT(*) FROM test GROUP BY a ORDER BY a DESC", true},
+			{"SELECT a FROM test ORDER BY a; SELECT 1", false},
+		}
+
+		for _, test := range tests {
+			res, err := db.Query(test.query)
+			require.NoError(t, err)
+
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			require.Equal(t, test.spilled, len(files) > 0, test.query)
+
+			err = res.Close()
+			require.NoError(t, err)
+
+			files, err = ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			require.Empty(t, files, test.query)
+		}
+	})
+
+	t.Run("partially read", func(t *testing.T) {
+		res, err := db.Query("SELECT a FROM test ORDER BY a")
+		require.NoError(t, err)
+
+		errStop := errors.New("stop")
+		err = res.Iterate(func(d document.Document) error {
+			return errStop
+		})
+		require.Equal(t, errStop, err)
+
+		err = res.Close()
+		require.NoError(t, err)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
+
+func TestSelectStmtStats(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY); CREATE INDEX idx_a ON test (a)")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = db.Exec("INSERT INTO test (k, a, b) VALUES (?, ?, ?)", i, i%5, i%2)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		expected query.Stats
+	}{
+		{"SELECT * FROM test", query.Stats{TableReads: 10, DocumentsMatched: 10}},
+		{"SELECT * FROM test WHERE b = 1", query.Stats{TableReads: 10, DocumentsMatched: 5}},
+		{"SELECT * FROM test WHERE k = 3", query.Stats{TableReads: 1, DocumentsMatched: 1}},
+		{"SELECT * FROM test WHERE a = 2", query.Stats{IndexReads: 3, TableReads: 2, DocumentsMatched: 2}},
+		{"SELECT * FROM test WHERE a = 2 AND b = 1", query.Stats{IndexReads: 3, TableReads: 2, DocumentsMatched: 1}},
+		// the limit stops the iteration when it reads the next document
+		{"SELECT * FROM test WHERE a >= 3 LIMIT 1", query.Stats{IndexReads: 2, TableReads: 2, DocumentsMatched: 2}},
+		// the extremes of an indexed field are read from the first or last entries of the index
+		{"SELECT a FROM test ORDER BY a LIMIT 1", query.Stats{IndexReads: 2, TableReads: 2, DocumentsMatched: 2}},
+		{"SELECT a FROM test ORDER BY a DESC LIMIT 1", query.Stats{IndexReads: 2, TableReads: 2, DocumentsMatched: 2}},
+		{"SELECT a FROM test ORDER BY b DESC LIMIT 1", query.Stats{TableReads: 10, DocumentsMatched: 10}},
+		// MIN and MAX on an indexed field only read one entry of the index
+		{"SELECT MIN(a) FROM test", query.Stats{IndexReads: 1, TableReads: 1}},
+		{"SELECT MAX(a) AS m FROM test", query.Stats{IndexReads: 1, TableReads: 1}},
+		{"SELECT MIN(a), MAX(a) FROM test HAVING MIN(a) >= 0", query.Stats{IndexReads: 2, TableReads: 2}},
+		{"SELECT MAX(a) FROM test WHERE b = 1", query.Stats{TableReads: 10, DocumentsMatched: 5}},
+		{"SELECT MAX(a), MAX(b) FROM test", query.Stats{TableReads: 10, DocumentsMatched: 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := parser.ParseQuery(test.query)
+			require.NoError(t, err)
+
+			var stats query.Stats
+			q.Stats = &stats
+
+			res, err := q.Run(db.DB, nil)
+			require.NoError(t, err)
+			defer res.Close()
+
+			err = res.Iterate(func(d document.Document) error { return nil })
+			require.NoError(t, err)
+
+			require.NotZero(t, stats.Elapsed)
+			stats.Elapsed = 0
+			require.Equal(t, test.expected, stats)
+		})
+	}
+}
+
+func TestSelectStmtLast(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY); CREATE INDEX idx_a ON test (a)")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = db.Exec("INSERT INTO test (k, a, b, c) VALUES (?, ?, ?, ?)", i, i%5, i%2, 9-i)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		expected int64
+		stats    query.Stats
+	}{
+		// tables and indexes are read in reverse
+		{"SELECT k FROM test", 9, query.Stats{TableReads: 1, DocumentsMatched: 1}},
+		{"SELECT k FROM test WHERE b = 0", 8, query.Stats{TableReads: 2, DocumentsMatched: 1}},
+		{"SELECT k FROM test ORDER BY k DESC", 0, query.Stats{TableReads: 1, DocumentsMatched: 1}},
+		{"SELECT k FROM test ORDER BY a", 9, query.Stats{IndexReads: 1, TableReads: 1, DocumentsMatched: 1}},
+		{"SELECT k FROM test ORDER BY a DESC", 0, query.Stats{IndexReads: 1, TableReads: 1, DocumentsMatched: 1}},
+		// other streams are read entirely
+		{"SELECT k FROM test OFFSET 7", 9, query.Stats{TableReads: 10, DocumentsMatched: 10}},
+		{"SELECT k FROM test WHERE a = 2", 7, query.Stats{IndexReads: 3, TableReads: 2, DocumentsMatched: 2}},
+		{"SELECT k FROM test ORDER BY c", 0, query.Stats{TableReads: 10, DocumentsMatched: 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := parser.ParseQuery(test.query)
+			require.NoError(t, err)
+
+			var stats query.Stats
+			q.Stats = &stats
+
+			res, err := q.Run(db.DB, nil)
+			require.NoError(t, err)
+			defer res.Close()
+
+			d, err := res.Last()
+			require.NoError(t, err)
+
+			var k int64
+			err = document.Scan(d, &k)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, k)
+
+			stats.Elapsed = 0
+			require.Equal(t, test.stats, stats)
+		})
+	}
+}
+
+func TestSelectStmtContains(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test;
+		INSERT INTO test (k, tags) VALUES (1, ['go', 'db']);
+		INSERT INTO test (k, tags) VALUES (2, ['rust']);
+		INSERT INTO test (k, tags) VALUES (3, 'go')`)
+	require.NoError(t, err)
+
+	st, err := db.Query("SELECT k FROM test WHERE tags CONTAINS 'go'")
+	require.NoError(t, err)
+	defer st.Close()
+
+	var buf bytes.Buffer
+	err = document.IteratorToJSONArray(&buf, st)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"k": 1}]`, buf.String())
+}
+
+func TestSelectStmtIndexMerge(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY); CREATE INDEX idx_a ON test (a); CREATE INDEX idx_b ON test (b)")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = db.Exec("INSERT INTO test (k, a, b, c) VALUES (?, ?, ?, ?)", i, i%5, i%2, i)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []int64
+		stats    query.Stats
+	}{
+		{"SELECT k FROM test WHERE a = 2 AND b = 1", []int64{7}, query.Stats{IndexReads: 8, TableReads: 1, DocumentsMatched: 1}},
+		// ranges are not merged, the equality is preferred and the other operands are filtered
+		{"SELECT k FROM test WHERE a >= 3 AND b = 0 AND c > 5", []int64{8}, query.Stats{IndexReads: 6, TableReads: 5, DocumentsMatched: 1}},
+		{"SELECT k FROM test WHERE a >= 3 AND b >= 1", []int64{3, 9}, query.Stats{IndexReads: 4, TableReads: 4, DocumentsMatched: 2}},
+		{"SELECT k FROM test WHERE a = 2 OR b >= 1", []int64{1, 2, 3, 5, 7, 9}, query.Stats{TableReads: 10, DocumentsMatched: 6}},
+		{"SELECT k FROM test WHERE a = 2 OR b = 0", []int64{0, 2, 4, 6, 7, 8}, query.Stats{IndexReads: 9, TableReads: 6, DocumentsMatched: 6}},
+		{"SELECT k FROM test WHERE a = 2 OR b = 0 ORDER BY k DESC", []int64{8, 7, 6, 4, 2, 0}, query.Stats{IndexReads: 9, TableReads: 6, DocumentsMatched: 6}},
+		// c is not indexed, the table must be scanned
+		{"SELECT k FROM test WHERE a = 2 OR c = 3", []int64{2, 3, 7}, query.Stats{TableReads: 10, DocumentsMatched: 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			q, err := parser.ParseQuery(test.query)
+			require.NoError(t, err)
+
+			var stats query.Stats
+			q.Stats = &stats
+
+			res, err := q.Run(db.DB, nil)
+			require.NoError(t, err)
+			defer res.Close()
+
+			var keys []int64
+			err = res.Iterate(func(d document.Document) error {
+				v, err := d.GetByField("k")
+				if err != nil {
+					return err
+				}
+				keys = append(keys, v.V.(int64))
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, keys)
+
+			stats.Elapsed = 0
+			require.Equal(t, test.stats, stats)
+		})
+	}
+}
+
+func seq(from, to, step int) []int {
+	var s []int
+	for i := from; i != to; i += step {
+		s = append(s, i)
+	}
+	return s
+}
+
+func TestSelectStmtIndexExtrema(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		// numbers are ordered before texts, like in an index
+		{"SELECT MIN(a), MAX(a) FROM test", `[{"MIN(a)": 2, "MAX(a)": "x"}]`},
+		// the index of d only holds documents and nulls, the table is scanned
+		{"SELECT MIN(d), MAX(d) FROM test", `[{"MIN(d)": {"b": 1}, "MAX(d)": {"b": 2}}]`},
+		{"SELECT MIN(a) FROM test WHERE b = 2", `[{"MIN(a)": null}]`},
+	}
+
+	for _, indexed := range []bool{false, true} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s/indexed: %v", test.query, indexed), func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec("CREATE TABLE test")
+				require.NoError(t, err)
+
+				if indexed {
+					err = db.Exec("CREATE INDEX idx_a ON test (a); CREATE INDEX idx_d ON test (d)")
+					require.NoError(t, err)
+				}
+
+				err = db.Exec(`
+					INSERT INTO test (a, d) VALUES (10, {b: 2});
+					INSERT INTO test (a) VALUES ('x');
+					INSERT INTO test (a, d) VALUES (2, {b: 1});
+					INSERT INTO test (a) VALUES (NULL);
+					INSERT INTO test (b) VALUES (1);
+				`)
+				require.NoError(t, err)
+
+				st, err := db.Query(test.query)
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	}
+}
+
+func TestSelectStmtGroupBy(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"aggregates", "SELECT status, COUNT(*), COUNT(n), SUM(n), AVG(n), MIN(n), MAX(n) FROM test GROUP BY status ORDER BY status",
+			`[
+				{"status": null, "COUNT(*)": 1, "COUNT(n)": 0, "SUM(n)": null, "AVG(n)": null, "MIN(n)": null, "MAX(n)": null},
+				{"status": "a", "COUNT(*)": 3, "COUNT(n)": 2, "SUM(n)": 12.5, "AVG(n)": 6.25, "MIN(n)": 2.5, "MAX(n)": 10},
+				{"status": "b", "COUNT(*)": 2, "COUNT(n)": 2, "SUM(n)": 6, "AVG(n)": 3, "MIN(n)": 1, "MAX(n)": 5}
+			]`},
+		{"where", "SELECT status, SUM(n) AS total FROM test WHERE k > 1 GROUP BY status ORDER BY status",
+			`[{"status": null, "total": null}, {"status": "a", "total": 2.5}, {"status": "b", "total": 6}]`},
+		{"order by and limit", "SELECT status, COUNT(*) AS c FROM test GROUP BY status ORDER BY c DESC LIMIT 1",
+			`[{"status": "a", "c": 3}]`},
+		{"documents without the grouped field", "SELECT status, COUNT(*) AS c FROM test GROUP BY status ORDER BY c LIMIT 1",
+			`[{"status": null, "c": 1}]`},
+		{"having", "SELECT status, COUNT(*) AS c FROM test GROUP BY status HAVING COUNT(*) > 2",
+			`[{"status": "a", "c": 3}]`},
+		{"having on unselected aggregate", "SELECT status FROM test GROUP BY status HAVING MAX(n) < 10",
+			`[{"status": "b"}]`},
+		{"having with order by and limit", "SELECT status, SUM(n) AS s FROM test GROUP BY status HAVING COUNT(n) >= 2 AND SUM(n) > 0 ORDER BY s DESC LIMIT 1",
+			`[{"status": "a", "s": 12.5}]`},
+		{"having on grouped field", "SELECT status, COUNT(*) AS c FROM test GROUP BY status HAVING status = 'b' ORDER BY c",
+			`[{"status": "b", "c": 2}]`},
+		{"having without group by", "SELECT COUNT(*) AS c FROM test HAVING COUNT(*) > 100",
+			`[]`},
+		{"having on alias", "SELECT status, COUNT(*) AS c FROM test GROUP BY status HAVING c > 2",
+			`[{"status": "a", "c": 3}]`},
+		{"having on alias without group by", "SELECT COUNT(*) AS c FROM test HAVING c > 0",
+			`[{"c": 6}]`},
+		{"nested field", "SELECT meta.kind, COUNT(*) AS c FROM test GROUP BY meta.kind ORDER BY meta.kind",
+			`[{"meta.kind": null, "c": 4}, {"meta.kind": 1, "c": 2}]`},
+		{"without group by", "SELECT COUNT(*) AS c, SUM(n) AS s FROM test",
+			`[{"c": 6, "s": 18.5}]`},
+		{"without group by and no match", "SELECT COUNT(*) AS c, SUM(n) AS s FROM test WHERE k > 100",
+			`[{"c": 0, "s": null}]`},
+		{"min and max of grouped field without group by", "SELECT MIN(status) AS min, MAX(status) AS max FROM test HAVING min < max",
+			`[{"min": "a", "max": "b"}]`},
+	}
+
+	for _, indexed := range []bool{false, true} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s/indexed: %v", test.name, indexed), func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY)")
+				require.NoError(t, err)
+
+				if indexed {
+					err = db.Exec("CREATE INDEX idx_status ON test (status)")
+					require.NoError(t, err)
+				}
+
+				err = db.Exec(`
+					INSERT INTO test (k, status, n) VALUES (1, 'a', 10);
+					INSERT INTO test (k, status, n, meta) VALUES (2, 'b', 5, {kind: 1});
+					INSERT INTO test (k, status, n) VALUES (3, 'a', 2.5);
+					INSERT INTO test (k, status, n, meta) VALUES (4, 'b', 1, {kind: 1});
+					INSERT INTO test (k, status) VALUES (5, 'a');
+					INSERT INTO test (k) VALUES (6)
+				`)
+				require.NoError(t, err)
+
+				st, err := db.Query(test.query)
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	}
+}
+
+// countingExpr counts how many times it is evaluated.
+type countingExpr struct {
+	n *int
+}
+
+func (c countingExpr) Eval(query.EvalStack) (document.Value, error) {
+	*c.n++
+	return document.NewIntValue(1), nil
+}
+
+func TestSelectStmtResultFieldLookup(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test; INSERT INTO test (a) VALUES (1), (2), (2), (3)`)
+	require.NoError(t, err)
+
+	var n int
+	stmt := query.SelectStmt{
+		TableName: "test",
+		Selectors: []query.ResultField{
+			query.ResultFieldExpr{Expr: countingExpr{&n}, ExprName: "x"},
+			query.ResultFieldExpr{Expr: query.CountFunc{}, ExprName: "c"},
+		},
+		GroupBy:          query.FieldSelector([]string{"a"}),
+		OrderBy:          query.FieldSelector([]string{"c"}),
+		OrderByDirection: scanner.DESC,
+	}
+
+	res, err := query.New(stmt).Run(db.DB, nil)
+	require.NoError(t, err)
+	defer res.Close()
+
+	var buf bytes.Buffer
+	err = document.IteratorToJSONArray(&buf, res)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"x": 1, "c": 2}, {"x": 1, "c": 1}, {"x": 1, "c": 1}]`, buf.String())
+
+	// looking up c to sort the groups must not evaluate x
+	require.Equal(t, 3, n)
+}
@@ -0,0 +1,220 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+)
+
+// sortRuns stores sorted runs of heap nodes in a temporary file.
+// Each run is a sequence of nodes, each node being encoded as
+// the uvarint encoded size of its value, the value, the uvarint encoded size of its data and the data.
+type sortRuns struct {
+	f       *os.File
+	w       *bufio.Writer
+	offsets []int64
+	size    int64
+	closed  bool
+}
+
+func newSortRuns(dir string) (*sortRuns, error) {
+	f, err := ioutil.TempFile(dir, "genji-sort-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &sortRuns{
+		f: f,
+		w: bufio.NewWriter(f),
+	}, nil
+}
+
+// spill pops the k first nodes of the heap, or all of them if k is 0,
+// writes them to a new run and empties the heap.
+func (s *sortRuns) spill(h heap.Interface, k int) error {
+	s.offsets = append(s.offsets, s.size)
+
+	var buf [binary.MaxVarintLen64]byte
+	for i := 0; h.Len() > 0 && (k == 0 || i < k); i++ {
+		node := heap.Pop(h).(heapNode)
+
+		for _, b := range [][]byte{node.value, node.data} {
+			n := binary.PutUvarint(buf[:], uint64(len(b)))
+			_, err := s.w.Write(buf[:n])
+			if err != nil {
+				return err
+			}
+			_, err = s.w.Write(b)
+			if err != nil {
+				return err
+			}
+			s.size += int64(n + len(b))
+		}
+	}
+
+	// only the k first nodes can be returned, the rest can be discarded.
+	for h.Len() > 0 {
+		heap.Pop(h)
+	}
+
+	return s.w.Flush()
+}
+
+// readers returns one reader per run.
+func (s *sortRuns) readers() []*bufio.Reader {
+	readers := make([]*bufio.Reader, len(s.offsets))
+	for i, off := range s.offsets {
+		end := s.size
+		if i+1 < len(s.offsets) {
+			end = s.offsets[i+1]
+		}
+
+		readers[i] = bufio.NewReader(io.NewSectionReader(s.f, off, end-off))
+	}
+
+	return readers
+}
+
+// Close closes and removes the temporary file.
+// It can be called multiple times.
+func (s *sortRuns) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	err := s.f.Close()
+	if rerr := os.Remove(s.f.Name()); err == nil {
+		err = rerr
+	}
+
+	return err
+}
+
+func readSortedNode(r *bufio.Reader) (heapNode, error) {
+	var node heapNode
+
+	for _, b := range []*[]byte{&node.value, &node.data} {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return node, err
+		}
+
+		*b = make([]byte, size)
+		_, err = io.ReadFull(r, *b)
+		if err != nil {
+			return node, err
+		}
+	}
+
+	return node, nil
+}
+
+// mergeSortedIterator merges the sorted runs with the content of the heap
+// and returns the k first documents, or all of them if k is 0.
+// The temporary file used to store the runs is removed once the iteration
+// is over, or when the result of the query is closed.
+type mergeSortedIterator struct {
+	h    heap.Interface
+	runs *sortRuns
+	k    int
+	desc bool
+}
+
+func (m *mergeSortedIterator) Iterate(fn func(d document.Document) error) error {
+	defer m.runs.Close()
+
+	readers := m.runs.readers()
+
+	mh := mergeHeap{desc: m.desc}
+
+	// the heap in memory is the source number -1
+	if m.h.Len() > 0 {
+		mh.nodes = append(mh.nodes, mergeNode{heapNode: heap.Pop(m.h).(heapNode), src: -1})
+	}
+
+	for i, r := range readers {
+		node, err := readSortedNode(r)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		mh.nodes = append(mh.nodes, mergeNode{heapNode: node, src: i})
+	}
+
+	heap.Init(&mh)
+
+	i := 0
+	for mh.Len() > 0 && (m.k == 0 || i < m.k) {
+		node := heap.Pop(&mh).(mergeNode)
+
+		err := fn(encoding.EncodedDocument(node.data))
+		if err != nil {
+			return err
+		}
+		i++
+
+		// fetch the next node from the same source
+		if node.src == -1 {
+			if m.h.Len() > 0 {
+				heap.Push(&mh, mergeNode{heapNode: heap.Pop(m.h).(heapNode), src: -1})
+			}
+			continue
+		}
+
+		next, err := readSortedNode(readers[node.src])
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		heap.Push(&mh, mergeNode{heapNode: next, src: node.src})
+	}
+
+	return nil
+}
+
+type mergeNode struct {
+	heapNode
+
+	src int
+}
+
+type mergeHeap struct {
+	nodes []mergeNode
+	desc  bool
+}
+
+func (h mergeHeap) Len() int      { return len(h.nodes) }
+func (h mergeHeap) Swap(i, j int) { h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i] }
+func (h mergeHeap) Less(i, j int) bool {
+	if h.desc {
+		return bytes.Compare(h.nodes[i].value, h.nodes[j].value) > 0
+	}
+
+	return bytes.Compare(h.nodes[i].value, h.nodes[j].value) < 0
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.nodes = append(h.nodes, x.(mergeNode))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := h.nodes
+	n := len(old)
+	x := old[n-1]
+	h.nodes = old[0 : n-1]
+	return x
+}
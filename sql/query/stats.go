@@ -0,0 +1,86 @@
+package query
+
+import (
+	"time"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/index"
+)
+
+// Stats holds runtime statistics about the execution of a query.
+// Counters are updated as the result is iterated over, they are complete
+// once the result has been consumed.
+// Only Select statements record statistics.
+type Stats struct {
+	// IndexReads is the number of entries read from an index.
+	IndexReads int
+	// TableReads is the number of documents fetched from the table,
+	// either during a full table scan, a primary key lookup or after an index lookup.
+	TableReads int
+	// DocumentsMatched is the number of documents that satisfied the where clause.
+	DocumentsMatched int
+	// Elapsed is the time spent planning the query and iterating over the result.
+	Elapsed time.Duration
+}
+
+// withStats returns a copy of stmt that records its statistics in s.
+// Statements that don't support statistics are returned unchanged.
+func withStats(stmt Statement, s *Stats) Statement {
+	switch t := stmt.(type) {
+	case SelectStmt:
+		t.stats = s
+		return t
+	}
+
+	return stmt
+}
+
+// statsIndex counts the entries read from the underlying index.
+type statsIndex struct {
+	index.Index
+
+	stats *Stats
+}
+
+func (i statsIndex) AscendGreaterOrEqual(pivot *index.Pivot, fn func(val document.Value, key []byte) error) error {
+	return i.Index.AscendGreaterOrEqual(pivot, func(val document.Value, key []byte) error {
+		i.stats.IndexReads++
+		return fn(val, key)
+	})
+}
+
+func (i statsIndex) DescendLessOrEqual(pivot *index.Pivot, fn func(val document.Value, key []byte) error) error {
+	return i.Index.DescendLessOrEqual(pivot, func(val document.Value, key []byte) error {
+		i.stats.IndexReads++
+		return fn(val, key)
+	})
+}
+
+// statsIterator adds the time spent iterating over it to the elapsed time.
+type statsIterator struct {
+	it    document.Iterator
+	stats *Stats
+}
+
+func (s statsIterator) Iterate(fn func(d document.Document) error) error {
+	start := time.Now()
+	err := s.it.Iterate(fn)
+	s.stats.Elapsed += time.Since(start)
+	return err
+}
+
+// Reverse returns a statsIterator reading the underlying iterator in reverse order,
+// or nil if it can't be reversed.
+func (s statsIterator) Reverse() document.Iterator {
+	r, ok := s.it.(document.ReversibleIterator)
+	if !ok {
+		return nil
+	}
+
+	it := r.Reverse()
+	if it == nil {
+		return nil
+	}
+
+	return statsIterator{it: it, stats: s.stats}
+}
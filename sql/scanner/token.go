@@ -55,6 +55,7 @@ const (
 	LTE      // <=
 	GT       // >
 	GTE      // >=
+	CONTAINS // CONTAINS
 	operatorEnd
 
 	LPAREN      // (
@@ -81,6 +82,8 @@ const (
 	DROP
 	EXISTS
 	FROM
+	GROUP
+	HAVING
 	IF
 	INDEX
 	INSERT
@@ -154,6 +157,7 @@ var tokens = [...]string{
 	LTE:      "<=",
 	GT:       ">",
 	GTE:      ">=",
+	CONTAINS: "CONTAINS",
 
 	LPAREN:      "(",
 	RPAREN:      ")",
@@ -178,6 +182,8 @@ var tokens = [...]string{
 	EXISTS:  "EXISTS",
 	KEY:     "KEY",
 	FROM:    "FROM",
+	GROUP:   "GROUP",
+	HAVING:  "HAVING",
 	IF:      "IF",
 	INDEX:   "INDEX",
 	INSERT:  "INSERT",
@@ -219,7 +225,7 @@ func init() {
 	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
 		keywords[strings.ToLower(tokens[tok])] = tok
 	}
-	for _, tok := range []Token{AND, OR, TRUE, FALSE, NULL} {
+	for _, tok := range []Token{AND, OR, CONTAINS, TRUE, FALSE, NULL} {
 		keywords[strings.ToLower(tokens[tok])] = tok
 	}
 }
@@ -239,7 +245,7 @@ func (tok Token) Precedence() int {
 		return 1
 	case AND:
 		return 2
-	case EQ, NEQ, EQREGEX, NEQREGEX, LT, LTE, GT, GTE:
+	case EQ, NEQ, EQREGEX, NEQREGEX, LT, LTE, GT, GTE, CONTAINS:
 		return 3
 	case ADD, SUB, BITWISEOR, BITWISEXOR:
 		return 4